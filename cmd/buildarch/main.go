@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"math/rand"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/nilsbu/arch/pkg/blueprint"
@@ -15,19 +19,124 @@ import (
 )
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
+	seed := flag.Int64("seed", 0, "seed for random decisions; a time-based seed is chosen when it is 0")
+	timeout := flag.Duration("timeout", 0, "maximum time for building the architecture; 0 means no limit")
+	candidates := flag.Int("candidates", 0, "maximum number of candidates that are checked; 0 means no limit")
+	samples := flag.Int("samples", 0, "number of values that are tried for each range or distribution; 0 means 3")
+	depth := flag.Int("depth", 0, "how often a blueprint may be nested in itself unless it sets @depth")
+	share := flag.Bool("share", false, "allow different constraint blueprints to be matched onto the same room")
+	convert := flag.String("convert", "", "write the blueprints in this format (json, yaml or toml) and don't build")
+	explain := flag.String("explain", "", "print what is visible at a child path like /Hall0/Room1 and don't build")
+	flag.Parse()
 
-	if err := buildArchitecture(); err != nil {
-		fmt.Println(err)
+	if *convert != "" {
+		if err := convertBlueprints(flag.Args(), *convert); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	if *explain != "" {
+		if err := explainBlueprints(flag.Args(), *explain); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	fmt.Fprintln(os.Stderr, "seed:", *seed)
+
+	opts := []merge.Option{merge.Timeout(*timeout), merge.MaxCandidates(*candidates), merge.Samples(*samples),
+		merge.MaxDepth(*depth)}
+	check := &csp.Matcher{ShareNodes: *share}
+	if err := buildArchitecture(flag.Args(), rand.New(rand.NewSource(*seed)), check, opts); err != nil {
+		fmt.Println(describe(err))
 	}
 }
 
-func buildArchitecture() error {
-	bps := make([]*blueprint.Blueprint, len(os.Args)-1)
-	for i := range bps {
-		if file, err := os.ReadFile(os.Args[i+1]); err != nil {
+// describe returns a summary of the failures if they are available.
+func describe(err error) string {
+	var nse *merge.NoSolutionError
+	var be *merge.BudgetError
+	if errors.As(err, &nse) {
+		return fmt.Sprintf("%v\n%v", merge.ErrNoSolution, nse.Report)
+	} else if errors.As(err, &be) && be.Report != nil {
+		return fmt.Sprintf("%v\n%v", be, be.Report)
+	} else {
+		return err.Error()
+	}
+}
+
+// convertBlueprints writes the blueprints in another format to stdout.
+func convertBlueprints(paths []string, name string) error {
+	format, err := blueprint.FormatByName(name)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if bp, err := blueprint.ParseFile(path); err != nil {
+			return err
+		} else if data, err := blueprint.Encode(bp, format); err != nil {
+			return err
+		} else {
+			os.Stdout.Write(data)
+		}
+	}
+	return nil
+}
+
+// explainBlueprints prints the properties and children that are visible from a node of each blueprint, where they
+// are defined and which definitions they shadow.
+func explainBlueprints(paths []string, path string) error {
+	for _, file := range paths {
+		bp, err := blueprint.ParseFile(file)
+		if err != nil {
 			return err
-		} else if bps[i], err = blueprint.Parse(file); err != nil {
+		}
+
+		node := bp
+		for _, name := range strings.Split(path, "/") {
+			if name == "" {
+				continue
+			} else if !strings.HasPrefix(name, "*") {
+				name = "*" + name
+			}
+			if node = node.Child(name); node == nil {
+				return fmt.Errorf("%v: there is no child '%v' in '%v'", file, name, path)
+			}
+		}
+
+		fmt.Printf("%v (%v)\n", path, node.Location())
+		for _, b := range node.ResolvedProperties() {
+			fmt.Printf("  %v = %v\n", b.Property, strings.Join(node.Values(b.Property), ", "))
+			explainBinding(node, b)
+		}
+		for _, b := range node.ResolvedChildren() {
+			fmt.Printf("  %v\n", b.Property)
+			explainBinding(node, b)
+		}
+	}
+	return nil
+}
+
+func explainBinding(node *blueprint.Blueprint, b blueprint.Binding) {
+	if b.Origin == node {
+		fmt.Printf("      defined at %v\n", b.Position)
+	} else {
+		fmt.Printf("      inherited from %v\n", b.Position)
+	}
+	for _, pos := range b.Shadowed {
+		fmt.Printf("      shadows %v\n", pos)
+	}
+}
+
+func buildArchitecture(paths []string, rnd *rand.Rand, check merge.Check, opts []merge.Option) error {
+	bps := make([]*blueprint.Blueprint, len(paths))
+	for i := range bps {
+		var err error
+		if bps[i], err = blueprint.ParseFile(paths[i]); err != nil {
 			return err
 		}
 	}
@@ -39,6 +148,8 @@ func buildArchitecture() error {
 			"House":         rule.House{},
 			"Corridor":      rule.Corridor{},
 			"RoomLine":      rule.RoomLine{},
+			"Grid":          rule.Grid{},
+			"BSP":           rule.BSP{},
 			"Frame":         rule.Frame{},
 			"Room":          rule.Room{},
 			"FurnishedRoom": rule.FurnishedRoom{},
@@ -50,11 +161,13 @@ func buildArchitecture() error {
 			"Path": rule.Path{},
 			"In":   rule.In{},
 		},
+		Depth: "@depth",
 	}
 
-	if g, err := merge.Build(bps, &csp.Centipede{}, resolver, merge.RandomOrder); err != nil {
+	if res, err := merge.Build(
+		context.Background(), bps, check, resolver, merge.RandomOrder, rnd, opts...); err != nil {
 		return err
-	} else if tiles, err := draw.Draw(g); err != nil {
+	} else if tiles, err := draw.Draw(res.Architecture); err != nil {
 		return err
 	} else {
 		render.Terminal(os.Stdout, tiles)
@@ -2,9 +2,10 @@ module github.com/nilsbu/arch
 
 go 1.18
 
-require github.com/gnboorse/centipede v1.0.2
-
 require (
 	github.com/nilsbu/async v0.1.0
-	golang.org/x/exp v0.0.0-20220318154914-8dddf5d87bd8 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+// The TOML reader uses the package unstable, which isn't covered by semantic versioning. Update only deliberately.
+require github.com/pelletier/go-toml/v2 v2.2.2
@@ -17,11 +17,20 @@ var ErrInvalidDoor = errors.New("door invalid")
 // deontes the right end. Any value in between can be used to denote other points of the line.
 //
 // The areas must touch in a line of non-zero length. If they aren't connected, overlap or touch in only a point, an
-// error is returned.
+// error is returned. Areas may be connected by several doors. The door must not lie on one of the line's corners.
 func CreateDoor(g *graph.Graph, nidx0, nidx1 graph.NodeIndex, position float64) error {
+	return CreateOpening(g, nidx0, nidx1, position, 1, Door)
+}
+
+// CreateOpening creates a DoorEdge like CreateDoor that is width tiles wide and of the given kind. The position
+// denotes the middle of the opening. If the width is even, the middle is the left one of the two middle tiles as seen
+// from the first area. The opening must fit on the line where the areas touch without covering one of its corners.
+func CreateOpening(g *graph.Graph, nidx0, nidx1 graph.NodeIndex, position float64, width int, kind DoorKind) error {
 	if position < 0 || position > 1 {
 		return fmt.Errorf("%w: position must be in range [0, 1] but was %v",
 			ErrInvalidDoor, position)
+	} else if width < 1 {
+		return fmt.Errorf("%w: width must be positive but was %v", ErrInvalidDoor, width)
 	}
 
 	node0, node1 := (*AreaNode)(g.Node(nidx0)), (*AreaNode)(g.Node(nidx1))
@@ -40,18 +49,46 @@ func CreateDoor(g *graph.Graph, nidx0, nidx1 graph.NodeIndex, position float64)
 	} else if inter, err := intersect(rect0, rect1); err != nil {
 		return err
 	} else {
-		if inter.X1 == rect0.X0 || inter.Y1 == rect0.Y0 {
+		flipped := inter.X1 == rect0.X0 || inter.Y1 == rect0.Y0
+		if flipped {
 			position = 1 - position
 		}
-		pt := Point{
-			X: inter.X0 + int(math.Round(float64(inter.X1-inter.X0)*position)),
-			Y: inter.Y0 + int(math.Round(float64(inter.Y1-inter.Y0)*position)),
+
+		// the line is either horizontal or vertical
+		dx, dy := 0, 1
+		if inter.X1 > inter.X0 {
+			dx, dy = 1, 0
+		}
+		length := inter.X1 - inter.X0 + inter.Y1 - inter.Y0
+		middle := int(math.Round(float64(length) * position))
+		// seen from the first area, the extra tile of openings with an even width lies to the right of the middle
+		start := middle - (width-1)/2
+		if flipped {
+			start = middle - width/2
 		}
-		(*DoorEdge)(g.Edge(eidx)).SetPos(pt)
+		if start < 1 || start+width-1 > length-1 {
+			return fmt.Errorf("%w: opening of width %v at %v doesn't fit on line %v without covering a corner",
+				ErrInvalidDoor, width, position, inter)
+		}
+
+		door := (*DoorEdge)(g.Edge(eidx))
+		door.SetPos(Point{X: inter.X0 + dx*middle, Y: inter.Y0 + dy*middle})
+		door.SetOpening(Rectangle{
+			X0: inter.X0 + dx*start, Y0: inter.Y0 + dy*start,
+			X1: inter.X0 + dx*(start+width-1), Y1: inter.Y0 + dy*(start+width-1),
+		})
+		door.SetKind(kind)
 		return nil
 	}
 }
 
+// Border returns the line in which two rectangles touch. ok is false if they don't touch in a line of non-zero length,
+// i.e. if CreateDoor couldn't link them.
+func Border(ar, br Rectangle) (line Rectangle, ok bool) {
+	line, err := intersect(ar, br)
+	return line, err == nil
+}
+
 func intersect(ar, br Rectangle) (Rectangle, error) {
 	x0 := max(ar.X0, br.X0)
 	y0 := max(ar.Y0, br.Y0)
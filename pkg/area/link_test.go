@@ -214,9 +214,125 @@ func TestCreateDoor(t *testing.T) {
 	}
 }
 
+func TestCreateDoorTwice(t *testing.T) {
+	g := graph.New(nil)
+	n0, _ := g.Add(graph.NodeIndex{})
+	g.Node(n0).Properties["rect"] = area.Rectangle{0, 0, 4, 8}
+	n1, _ := g.Add(graph.NodeIndex{})
+	g.Node(n1).Properties["rect"] = area.Rectangle{4, 0, 8, 8}
+
+	for _, position := range []float64{.25, .75} {
+		if err := area.CreateDoor(g, n0, n1, position); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	edges := g.Node(n0).Edges
+	if len(edges) != 2 {
+		t.Fatalf("expected 2 doors but got %v", len(edges))
+	}
+	for i, expect := range []area.Point{{4, 2}, {4, 6}} {
+		if pos := (*area.DoorEdge)(g.Edge(edges[i])).GetPos(); pos != expect {
+			t.Errorf("door %v: expect %v, actual %v", i, expect, pos)
+		}
+	}
+}
+
+func TestCreateOpening(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		flip     bool
+		position float64
+		width    int
+		pos      area.Point
+		opening  area.Rectangle
+		err      error
+	}{
+		{"single tile", false, .5, 1, area.Point{4, 4}, area.Rectangle{4, 4, 4, 4}, nil},
+		{"odd width", false, .5, 3, area.Point{4, 4}, area.Rectangle{4, 3, 4, 5}, nil},
+		{"even width", false, .5, 2, area.Point{4, 4}, area.Rectangle{4, 4, 4, 5}, nil},
+		{"even width flipped", true, .5, 2, area.Point{4, 4}, area.Rectangle{4, 3, 4, 4}, nil},
+		{"whole wall", false, .5, 7, area.Point{4, 4}, area.Rectangle{4, 1, 4, 7}, nil},
+		{"covers corner", false, .5, 8, area.Point{}, area.Rectangle{}, area.ErrInvalidDoor},
+		{"at corner", false, 0, 1, area.Point{}, area.Rectangle{}, area.ErrInvalidDoor},
+		{"close to corner", false, .125, 3, area.Point{}, area.Rectangle{}, area.ErrInvalidDoor},
+		{"no width", false, .5, 0, area.Point{}, area.Rectangle{}, area.ErrInvalidDoor},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			g := graph.New(nil)
+			n0, _ := g.Add(graph.NodeIndex{})
+			g.Node(n0).Properties["rect"] = area.Rectangle{0, 0, 4, 8}
+			n1, _ := g.Add(graph.NodeIndex{})
+			g.Node(n1).Properties["rect"] = area.Rectangle{4, 0, 8, 8}
+			if c.flip {
+				n0, n1 = n1, n0
+			}
+
+			if err := area.CreateOpening(g, n0, n1, c.position, c.width, area.Archway); err != nil && c.err == nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if err == nil && c.err != nil {
+				t.Errorf("expected error but non ocurred")
+			} else if !errors.Is(err, c.err) {
+				t.Errorf("wrong type of error\nexpect: %v\nactual: %v", c.err, err)
+			} else if err == nil {
+				door := (*area.DoorEdge)(g.Edge(g.Node(n0).Edges[0]))
+				if pos := door.GetPos(); pos != c.pos {
+					t.Errorf("wrong position: expect %v, actual %v", c.pos, pos)
+				}
+				if opening := door.GetOpening(); opening != c.opening {
+					t.Errorf("wrong opening: expect %v, actual %v", c.opening, opening)
+				}
+				if width := door.GetWidth(); width != c.width {
+					t.Errorf("wrong width: expect %v, actual %v", c.width, width)
+				}
+				if kind := door.GetKind(); kind != area.Archway {
+					t.Errorf("wrong kind: expect %v, actual %v", area.Archway, kind)
+				}
+			}
+		})
+	}
+}
+
+func TestDoorKindByName(t *testing.T) {
+	for _, kind := range []area.DoorKind{area.Door, area.Archway, area.Gate, area.Locked} {
+		if actual, err := area.DoorKindByName(kind.String()); err != nil {
+			t.Errorf("unexpected error for %v: %v", kind, err)
+		} else if actual != kind {
+			t.Errorf("expect %v, actual %v", kind, actual)
+		}
+	}
+
+	if _, err := area.DoorKindByName("portal"); !errors.Is(err, area.ErrInvalidDoor) {
+		t.Errorf("expected ErrInvalidDoor but got %v", err)
+	}
+}
+
 func TestGetUnsetPos(t *testing.T) {
 	door := &area.DoorEdge{Properties: graph.Properties{}}
 	if !reflect.DeepEqual(door.GetPos(), area.Point{}) {
 		t.Errorf("door position was (unfathomably) set: %v", door.GetPos())
 	}
 }
+
+func TestBorder(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		a, b area.Rectangle
+		line area.Rectangle
+		ok   bool
+	}{
+		{"vertical", area.Rectangle{0, 0, 4, 6}, area.Rectangle{4, 2, 8, 9}, area.Rectangle{4, 2, 4, 6}, true},
+		{"horizontal", area.Rectangle{0, 0, 4, 6}, area.Rectangle{1, 6, 3, 9}, area.Rectangle{1, 6, 3, 6}, true},
+		{"corner", area.Rectangle{0, 0, 4, 6}, area.Rectangle{4, 6, 8, 9}, area.Rectangle{}, false},
+		{"apart", area.Rectangle{0, 0, 4, 6}, area.Rectangle{5, 0, 8, 6}, area.Rectangle{}, false},
+		{"overlap", area.Rectangle{0, 0, 4, 6}, area.Rectangle{2, 2, 8, 9}, area.Rectangle{}, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if line, ok := area.Border(c.a, c.b); ok != c.ok {
+				t.Errorf("expected ok = %v but got %v", c.ok, ok)
+			} else if line != c.line {
+				t.Errorf("expected line %v but got %v", c.line, line)
+			}
+		})
+	}
+}
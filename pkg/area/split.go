@@ -3,6 +3,8 @@ package area
 import (
 	"errors"
 	"fmt"
+	"math"
+	"sort"
 
 	"github.com/nilsbu/arch/pkg/graph"
 )
@@ -17,7 +19,7 @@ var ErrInvalidSplit = errors.New("invalid split")
 // "direction" is the direction along which the areas are aligned. E.g. if Down is chosen, the first resulting area is
 // the hightest (smallest y value), and the other ones follow below it.
 // "at" is a sequence of numbers in range [0, 1] determining where along the splitting axis the borders between the
-// areas lie.
+// areas lie. The borders are rounded to the nearest tile.
 func Split(g *graph.Graph, base graph.NodeIndex, into []graph.NodeIndex, at []float64, direction Direction) error {
 	if len(into) != len(at)+1 {
 		return fmt.Errorf("%w: tried to split into %v nodes with %v dividers", ErrInvalidSplit, len(into), len(at))
@@ -46,11 +48,134 @@ func flip(rect Rectangle, direction Direction) Rectangle {
 	}
 }
 
+// crop cuts a part out of a rect. The borders are rounded to the nearest tile.
 func crop(rect Rectangle, from, to float64) Rectangle {
 	return Rectangle{
 		rect.X0,
-		rect.Y0 + int(float64(rect.Y1-rect.Y0)*from),
+		rect.Y0 + int(math.Round(float64(rect.Y1-rect.Y0)*from)),
 		rect.X1,
-		rect.Y0 + int(float64(rect.Y1-rect.Y0)*to),
+		rect.Y0 + int(math.Round(float64(rect.Y1-rect.Y0)*to)),
 	}
 }
+
+// A Share describes how big a part of a split is.
+type Share struct {
+	// Weight is the size of the part relative to the others. It must be positive.
+	Weight float64
+	// Min and Max limit the number of tiles inside of the part. Every part gets at least one tile. A Max of 0 means
+	// that there is no upper limit.
+	Min, Max int
+}
+
+// SplitShares splits an area like Split does, but the sizes of the parts follow shares instead of fixed borders.
+// The walls between the parts take one tile each. The remaining tiles are divided proportionally to the weights,
+// where parts that would end up too small or too big get their minimum or maximum instead. The borders are then
+// rounded down one after the other, so that leftover tiles go to the parts in a deterministic way.
+// ErrInvalidSplit is returned if the shares cannot be met.
+func SplitShares(
+	g *graph.Graph, base graph.NodeIndex, into []graph.NodeIndex, shares []Share, direction Direction,
+) error {
+	if len(into) != len(shares) {
+		return fmt.Errorf("%w: tried to split into %v nodes with %v shares", ErrInvalidSplit, len(into), len(shares))
+	}
+
+	flipped := flip((*AreaNode)(g.Node(base)).GetRect(), direction)
+	length, sign := flipped.Y1-flipped.Y0, 1
+	if length < 0 {
+		length, sign = -length, -1
+	}
+
+	sizes, err := Sizes(length-len(shares), shares)
+	if err != nil {
+		return err
+	}
+
+	y := flipped.Y0
+	for i, size := range sizes {
+		next := y + sign*(size+1)
+		(*AreaNode)(g.Node(into[i])).SetRect(flip(Rectangle{flipped.X0, y, flipped.X1, next}, direction))
+		y = next
+	}
+	return nil
+}
+
+// Sizes divides a number of tiles among parts according to their shares, see SplitShares.
+func Sizes(tiles int, shares []Share) ([]int, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("%w: no shares", ErrInvalidSplit)
+	}
+
+	min, max := make([]int, len(shares)), make([]int, len(shares))
+	sumMin, sumMax := 0, 0
+	for i, s := range shares {
+		if !(s.Weight > 0) || math.IsInf(s.Weight, 0) {
+			return nil, fmt.Errorf("%w: weight %v isn't positive", ErrInvalidSplit, s.Weight)
+		} else if s.Max < 0 || s.Max > 0 && s.Max < s.Min {
+			return nil, fmt.Errorf("%w: maximum %v is below minimum %v", ErrInvalidSplit, s.Max, s.Min)
+		}
+
+		min[i], max[i] = s.Min, s.Max
+		if min[i] < 1 {
+			min[i] = 1
+		}
+		if max[i] == 0 || max[i] > tiles {
+			// no part can take more than all tiles
+			max[i] = tiles
+		}
+		sumMin += min[i]
+		sumMax += max[i]
+	}
+	if sumMin > tiles {
+		return nil, fmt.Errorf("%w: parts need at least %v tiles but there are %v", ErrInvalidSplit, sumMin, tiles)
+	} else if sumMax < tiles {
+		return nil, fmt.Errorf("%w: parts take at most %v tiles but there are %v", ErrInvalidSplit, sumMax, tiles)
+	}
+
+	// Every part gets scale*weight tiles, limited to its minimum and maximum, for the scale at which all tiles are
+	// used. Between the scales at which a part reaches one of its limits, the total grows linearly, so the scale can
+	// be interpolated between them.
+	total := func(scale float64) float64 {
+		sum := 0.
+		for i, s := range shares {
+			sum += math.Min(math.Max(scale*s.Weight, float64(min[i])), float64(max[i]))
+		}
+		return sum
+	}
+	points := []float64{0}
+	for i, s := range shares {
+		points = append(points, float64(min[i])/s.Weight, float64(max[i])/s.Weight)
+	}
+	sort.Float64s(points)
+
+	scale := points[len(points)-1]
+	for k := 1; k < len(points); k++ {
+		if hi := total(points[k]); hi >= float64(tiles) {
+			lo := total(points[k-1])
+			if hi > lo {
+				scale = points[k-1] + (float64(tiles)-lo)*(points[k]-points[k-1])/(hi-lo)
+			} else {
+				scale = points[k-1]
+			}
+			break
+		}
+	}
+
+	ideal := make([]float64, len(shares))
+	for i, s := range shares {
+		ideal[i] = math.Min(math.Max(scale*s.Weight, float64(min[i])), float64(max[i]))
+	}
+
+	sizes := make([]int, len(shares))
+	sum, prev := 0., 0
+	for i := range shares {
+		sum += ideal[i]
+		// the tolerance keeps borders that are whole numbers from being moved by floating point errors
+		border := int(math.Floor(sum + 1e-9))
+		if i == len(shares)-1 {
+			border = tiles
+		}
+		sizes[i] = border - prev
+		prev = border
+	}
+	return sizes, nil
+}
@@ -87,6 +87,26 @@ func TestSplit(t *testing.T) {
 			[]area.Rectangle{{10, 0, 12, 77}, {8, 0, 10, 77}, {6, 0, 8, 77}, {2, 0, 6, 77}},
 			nil,
 		},
+		{
+			"borders are rounded",
+			graph.Properties{
+				"rect": area.Rectangle{0, 0, 2, 10},
+			},
+			[]float64{.29},
+			area.Down,
+			[]area.Rectangle{{0, 0, 2, 3}, {0, 3, 2, 10}},
+			nil,
+		},
+		{
+			"borders aren't moved by floating point errors",
+			graph.Properties{
+				"rect": area.Rectangle{0, 0, 100, 2},
+			},
+			[]float64{.58},
+			area.Right,
+			[]area.Rectangle{{0, 0, 58, 2}, {58, 0, 100, 2}},
+			nil,
+		},
 	} {
 		t.Run(c.name, func(t *testing.T) {
 			g := graph.New(nil)
@@ -110,3 +130,123 @@ func TestSplit(t *testing.T) {
 		})
 	}
 }
+
+func TestSizes(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		tiles  int
+		shares []area.Share
+		sizes  []int
+		err    error
+	}{
+		{"no shares", 5, []area.Share{}, nil, area.ErrInvalidSplit},
+		{"one part", 5, []area.Share{{1, 0, 0}}, []int{5}, nil},
+		{"equal weights", 9, []area.Share{{1, 0, 0}, {1, 0, 0}, {1, 0, 0}}, []int{3, 3, 3}, nil},
+		{"leftover goes to later parts", 10, []area.Share{{1, 0, 0}, {1, 0, 0}, {1, 0, 0}}, []int{3, 3, 4}, nil},
+		{"weights", 12, []area.Share{{1, 0, 0}, {2, 0, 0}, {3, 0, 0}}, []int{2, 4, 6}, nil},
+		{"minimum", 12, []area.Share{{1, 5, 0}, {1, 0, 0}}, []int{6, 6}, nil},
+		{"minimum takes from others", 12, []area.Share{{1, 0, 0}, {5, 0, 0}, {1, 4, 0}}, []int{1, 7, 4}, nil},
+		{"maximum", 12, []area.Share{{1, 0, 2}, {1, 0, 0}, {1, 0, 0}}, []int{2, 5, 5}, nil},
+		{"minimum and maximum", 20, []area.Share{{1, 5, 0}, {10, 0, 2}, {1, 0, 0}}, []int{9, 2, 9}, nil},
+		{"at least one tile", 10, []area.Share{{1, 0, 0}, {100, 0, 0}}, []int{1, 9}, nil},
+		{"too few tiles", 5, []area.Share{{1, 3, 0}, {1, 3, 0}}, nil, area.ErrInvalidSplit},
+		{"too many tiles", 5, []area.Share{{1, 0, 2}, {1, 0, 2}}, nil, area.ErrInvalidSplit},
+		{"zero weight", 5, []area.Share{{0, 0, 0}}, nil, area.ErrInvalidSplit},
+		{"maximum below minimum", 5, []area.Share{{1, 3, 2}}, nil, area.ErrInvalidSplit},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			sizes, err := area.Sizes(c.tiles, c.shares)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expected error %v but got %v", c.err, err)
+			} else if !reflect.DeepEqual(c.sizes, sizes) {
+				t.Errorf("expected sizes %v but got %v", c.sizes, sizes)
+			}
+		})
+	}
+}
+
+func TestSplitShares(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		rect      area.Rectangle
+		shares    []area.Share
+		direction area.Direction
+		out       []area.Rectangle
+	}{
+		{
+			"down",
+			area.Rectangle{2, 0, 4, 10},
+			[]area.Share{{1, 0, 0}, {3, 0, 0}},
+			area.Down,
+			[]area.Rectangle{{2, 0, 4, 3}, {2, 3, 4, 10}},
+		},
+		{
+			"up",
+			area.Rectangle{2, 0, 4, 10},
+			[]area.Share{{1, 0, 0}, {3, 0, 0}},
+			area.Up,
+			[]area.Rectangle{{2, 7, 4, 10}, {2, 0, 4, 7}},
+		},
+		{
+			"left with maximum",
+			area.Rectangle{0, 2, 12, 5},
+			[]area.Share{{1, 0, 3}, {1, 0, 0}},
+			area.Left,
+			[]area.Rectangle{{8, 2, 12, 5}, {0, 2, 8, 5}},
+		},
+		{
+			"equal shares leave the leftover tile to the last part",
+			area.Rectangle{2, 0, 12, 77},
+			[]area.Share{{1, 0, 0}, {1, 0, 0}, {1, 0, 0}},
+			area.Right,
+			[]area.Rectangle{{2, 0, 5, 77}, {5, 0, 8, 77}, {8, 0, 12, 77}},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			g := graph.New(nil)
+			fillNodes(g, graph.Properties{"rect": c.rect}, len(c.out))
+			root := graph.NodeIndex{}
+			if err := area.SplitShares(g, root, g.Children(root), c.shares, c.direction); err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			for i, cnidx := range g.Children(root) {
+				if actual := (*area.AreaNode)(g.Node(cnidx)).GetRect(); !reflect.DeepEqual(c.out[i], actual) {
+					t.Errorf("rect %v, expect %v, actual %v", i, c.out[i], actual)
+				}
+			}
+		})
+	}
+}
+
+// TestSplitSharesRoundsDown shows how SplitShares differs from Split with the same proportions: Split rounds every
+// border to the nearest tile, SplitShares rounds the borders down one after the other.
+func TestSplitSharesRoundsDown(t *testing.T) {
+	rect := area.Rectangle{2, 0, 12, 77}
+	rects := func(split func(g *graph.Graph, root graph.NodeIndex) error) []area.Rectangle {
+		g := graph.New(nil)
+		fillNodes(g, graph.Properties{"rect": rect}, 3)
+		root := graph.NodeIndex{}
+		if err := split(g, root); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		var out []area.Rectangle
+		for _, cnidx := range g.Children(root) {
+			out = append(out, (*area.AreaNode)(g.Node(cnidx)).GetRect())
+		}
+		return out
+	}
+
+	split := rects(func(g *graph.Graph, root graph.NodeIndex) error {
+		return area.Split(g, root, g.Children(root), []float64{1. / 3, 2. / 3}, area.Right)
+	})
+	shares := rects(func(g *graph.Graph, root graph.NodeIndex) error {
+		return area.SplitShares(g, root, g.Children(root), []area.Share{{1, 0, 0}, {1, 0, 0}, {1, 0, 0}}, area.Right)
+	})
+
+	if expect := []area.Rectangle{{2, 0, 5, 77}, {5, 0, 9, 77}, {9, 0, 12, 77}}; !reflect.DeepEqual(expect, split) {
+		t.Errorf("Split: expected %v but got %v", expect, split)
+	}
+	if expect := []area.Rectangle{{2, 0, 5, 77}, {5, 0, 8, 77}, {8, 0, 12, 77}}; !reflect.DeepEqual(expect, shares) {
+		t.Errorf("SplitShares: expected %v but got %v", expect, shares)
+	}
+}
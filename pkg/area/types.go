@@ -1,6 +1,11 @@
 package area
 
-import "github.com/nilsbu/arch/pkg/graph"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nilsbu/arch/pkg/graph"
+)
 
 // AreaNode is a node that has a rectangular area.
 // The rect will default to {0, 0, 0, 0} is not otherwise specified.
@@ -31,6 +36,8 @@ type Rectangle struct {
 // DoorEdge that represents a door.
 // It uses the property "pos" to define the position of the door. The orientation is defined implicitely by the nodes
 // that it connects. The position defaults to [0, 0] when not set.
+// Doors may be wider than one tile. The tiles they take up on the wall are stored in the property "opening", which
+// defaults to the position alone. The property "kind" holds the DoorKind, which defaults to Door.
 type DoorEdge graph.Edge
 
 // GetPos returns the position of the door.
@@ -47,6 +54,71 @@ func (e *DoorEdge) SetPos(pos Point) {
 	e.Properties["pos"] = pos
 }
 
+// GetOpening returns the tiles of the wall that the door takes up. It is a line that contains the position.
+func (e *DoorEdge) GetOpening() Rectangle {
+	if opening, ok := e.Properties["opening"]; ok {
+		return opening.(Rectangle)
+	} else {
+		pos := e.GetPos()
+		return Rectangle{pos.X, pos.Y, pos.X, pos.Y}
+	}
+}
+
+// SetOpening sets the tiles of the wall that the door takes up.
+func (e *DoorEdge) SetOpening(opening Rectangle) {
+	e.Properties["opening"] = opening
+}
+
+// GetWidth returns the number of tiles that the door takes up.
+func (e *DoorEdge) GetWidth() int {
+	opening := e.GetOpening()
+	return opening.X1 - opening.X0 + opening.Y1 - opening.Y0 + 1
+}
+
+// GetKind returns the kind of the door.
+func (e *DoorEdge) GetKind() DoorKind {
+	if kind, ok := e.Properties["kind"]; ok {
+		return kind.(DoorKind)
+	} else {
+		return Door
+	}
+}
+
+// SetKind sets the kind of the door.
+func (e *DoorEdge) SetKind(kind DoorKind) {
+	e.Properties["kind"] = kind
+}
+
+// DoorKind specifies what kind of opening a door is.
+type DoorKind byte
+
+const (
+	Door DoorKind = iota
+	Archway
+	Gate
+	Locked
+)
+
+var doorKinds = []string{"door", "archway", "gate", "locked"}
+
+// DoorKindByName returns the kind of door with the name that String() returns.
+func DoorKindByName(name string) (DoorKind, error) {
+	for i, kindName := range doorKinds {
+		if kindName == name {
+			return DoorKind(i), nil
+		}
+	}
+	return Door, fmt.Errorf("%w: unknown kind '%v', known are: %v", ErrInvalidDoor, name, strings.Join(doorKinds, ", "))
+}
+
+func (k DoorKind) String() string {
+	if int(k) < len(doorKinds) {
+		return doorKinds[k]
+	} else {
+		return fmt.Sprintf("DoorKind(%v)", byte(k))
+	}
+}
+
 // Point specifies a position.
 type Point struct {
 	X, Y int
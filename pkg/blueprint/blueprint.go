@@ -1,9 +1,13 @@
 package blueprint
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 // ErrInvalidScript is returned when a script isn't valid.
@@ -11,8 +15,9 @@ var ErrInvalidScript = errors.New("blueprint script is invalid")
 
 // A Blueprint describes an aspect of a level.
 // Properties of a Blueprint are organized in two ways: children and values. Children are themselves blueprints.
-// Values are of type []string. They both are accessibly via their property name. All names of children start with '*'
-// and none of the names of values do.
+// Values are strings, numbers, booleans, tuples or distributions, see Distribution. Values() returns them as strings
+// and TypedValues() with their types. They both are accessibly via their property name. All names of children start
+// with '*' and none of the names of values do.
 //
 // Through the children, a tree structure is defined. Each node may have an arbitrary number of children and the
 // tree may have any height.
@@ -21,91 +26,380 @@ var ErrInvalidScript = errors.New("blueprint script is invalid")
 // no property of that name has been defined there, the parents are recursively called until a property of that name is
 // found.
 //
+// String values may carry a weight, which is used when they are alternatives. It is written as a suffix of the form
+// "*<weight>", e.g. "TwoRooms*3". The suffix is stripped from the value and the weight can be accessed through
+// Weights(). This applies to single values and to values in lists alike. Values without a suffix have weight 1. A
+// string that ends in something like "*2" itself is written with an explicit weight, e.g. "a*2*1".
+//
+// A Blueprint may include other files through the property "@include", which contains one path or a list of paths
+// relative to the including file. The included blueprints are searched for properties that aren't defined in the
+// including one itself, in the order in which they are listed, before its parents are. Included blueprints have no
+// parent, so their children don't see the properties of the including blueprint.
+//
+// Templates are instantiated and repetitions unrolled when a blueprint is parsed, see Template.
+//
 // Properties can get parsed from files.
 type Blueprint struct {
 	parent   *Blueprint
-	values   map[string][]string
-	children map[string]*Blueprint
+	includes []*Blueprint
+	location Position
+	// keys are the properties in the order in which they are defined and included the paths of the includes as they
+	// are written. Both are used to write the blueprint back out.
+	keys      []string
+	included  []string
+	values    map[string][]string
+	typed     map[string][]Value
+	weights   map[string][]float64
+	positions map[string]Position
+	children  map[string]*Blueprint
+	// lists are the properties whose values were written as a list. They are written as a list again.
+	lists map[string]bool
+	// source is the blueprint as it was before templates were instantiated and repetitions were unrolled in it. It is
+	// written instead of the blueprint.
+	source *Blueprint
 }
 
-// TODO Properties() and Children() don't contain those reachable through parent
+// Include is the name of the property through which other files are included.
+const Include = "@include"
 
-// Parse creates a Blueprint from the content of a file.
+// Parse creates a Blueprint from the content of a JSON file.
+// Since there is no file to resolve them against, includes aren't supported. Use ParseFile for that.
 //
-// An *json.InvalidUnmarshalError is returned when the file is no valid JSON.
-// An ErrInvalidScript is returned when the content isn't valid.
+// Errors are *PositionErrors that state where in the content they occurred. They wrap ErrInvalidScript when the
+// content isn't valid and a *json.SyntaxError when it isn't valid JSON.
 func Parse(data []byte) (*Blueprint, error) {
-	var raw map[string]interface{}
-	err := json.Unmarshal(data, &raw)
+	return ParseAs(data, JSON)
+}
+
+// ParseAs creates a Blueprint from content in the given format. Like Parse, it doesn't support includes.
+func ParseAs(data []byte, format Format) (*Blueprint, error) {
+	return (&parser{}).parse(data, format)
+}
+
+// ParseFile creates a Blueprint from a file and the files it includes. The format of each file is determined by its
+// extension, see FormatOf, so files may include files of other formats.
+// A file that is included several times is only parsed once. Including a file that includes the including file again,
+// directly or indirectly, results in an ErrInvalidScript.
+func ParseFile(path string) (*Blueprint, error) {
+	p := &parser{files: map[string]*Blueprint{}}
+	return p.parseFile(path, path)
+}
+
+// A parser parses a single file. Parsers for included files share the files that have been parsed and those that are
+// being parsed.
+type parser struct {
+	// path is the absolute path of the file that is being parsed and name the one that is shown in positions. Both are
+	// empty if the data doesn't come from a file.
+	path  string
+	name  string
+	stack []string
+	files map[string]*Blueprint
+}
+
+func (p *parser) parseFile(name, path string) (*Blueprint, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, open := range p.stack {
+		if open == path {
+			return nil, fmt.Errorf("%w: include cycle %v", ErrInvalidScript,
+				strings.Join(append(p.stack[i:], path), " -> "))
+		}
+	}
+	if bp, ok := p.files[path]; ok {
+		return bp, nil
+	}
+
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("cannot parse JSON: %w", err)
+		return nil, err
 	}
 
-	return parseRaw(raw, nil)
+	sub := &parser{path: path, name: name, stack: append(p.stack[:len(p.stack):len(p.stack)], path), files: p.files}
+	bp, err := sub.parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+	p.files[path] = bp
+	return bp, nil
 }
 
-func parseRaw(raw map[string]interface{}, parent *Blueprint) (*Blueprint, error) {
-	bp := &Blueprint{
-		parent:   parent,
-		values:   map[string][]string{},
-		children: map[string]*Blueprint{},
+func (p *parser) parse(data []byte, format Format) (*Blueprint, error) {
+	obj, err := decodeFormat(data, format)
+	if err != nil {
+		var pe *PositionError
+		if errors.As(err, &pe) {
+			pe.Position.File = p.name
+		}
+		return nil, err
 	}
 
-	for k, v := range raw {
+	bp, err := p.parseObject(obj, nil, "")
+	if err != nil {
+		return nil, err
+	} else if err := (&expander{}).expand(bp); err != nil {
+		return nil, err
+	}
+	return bp, nil
+}
+
+func (p *parser) at(line int, path string) Position {
+	return Position{File: p.name, Line: line, Path: path}
+}
+
+// parseObject creates a Blueprint from an object that is reached through path.
+func (p *parser) parseObject(obj *object, parent *Blueprint, path string) (*Blueprint, error) {
+	bp := newBlueprint(parent, p.at(obj.line, path))
+
+	for _, k := range obj.keys {
+		m := obj.members[k]
+		if k == Include {
+			if err := p.include(bp, m, join(path, k)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
 		valueCounter := 0
-		if strs, err := bp.parseValues(v, k, &valueCounter); err != nil {
+		if values, weights, err := p.parseValues(bp, m, k, join(path, k), &valueCounter); err != nil {
 			return nil, err
 		} else {
-			bp.values[k] = strs
+			bp.keys = append(bp.keys, k)
+			bp.typed[k] = values
+			bp.values[k] = make([]string, len(values))
+			for i, v := range values {
+				bp.values[k][i] = v.String()
+			}
+			bp.weights[k] = weights
+			bp.positions[k] = p.at(m.line, join(path, k))
+			if _, ok := m.value.([]*member); ok {
+				bp.lists[k] = true
+			}
 		}
 	}
 
 	return bp, nil
 }
 
-func (b *Blueprint) parseValues(raw interface{}, k string, valueCounter *int) ([]string, error) {
-	switch value := raw.(type) {
+func newBlueprint(parent *Blueprint, location Position) *Blueprint {
+	return &Blueprint{
+		parent:    parent,
+		location:  location,
+		values:    map[string][]string{},
+		typed:     map[string][]Value{},
+		weights:   map[string][]float64{},
+		positions: map[string]Position{},
+		children:  map[string]*Blueprint{},
+		lists:     map[string]bool{},
+	}
+}
+
+// include parses the files listed in m and adds them to the includes of bp.
+func (p *parser) include(bp *Blueprint, m *member, path string) error {
+	var elems []*member
+	switch value := m.value.(type) {
+	case string:
+		elems = []*member{m}
+	case []*member:
+		elems = value
+	default:
+		return &PositionError{p.at(m.line, path),
+			fmt.Errorf("%w: '%v' is not a valid path to include", ErrInvalidScript, m.value)}
+	}
+
+	for _, elem := range elems {
+		name, ok := elem.value.(string)
+		if !ok {
+			return &PositionError{p.at(elem.line, path),
+				fmt.Errorf("%w: '%v' is not a valid path to include", ErrInvalidScript, elem.value)}
+		} else if p.files == nil {
+			return &PositionError{p.at(elem.line, path),
+				fmt.Errorf("%w: cannot include '%v' without a file to resolve it against", ErrInvalidScript, name)}
+		}
+
+		file := name
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(p.name), name)
+			file = filepath.Join(filepath.Dir(p.path), file)
+		}
+		if included, err := p.parseFile(name, file); err != nil {
+			return &PositionError{p.at(elem.line, path), fmt.Errorf("cannot include '%v': %w", name, err)}
+		} else {
+			bp.includes = append(bp.includes, included)
+			bp.included = append(bp.included, elem.value.(string))
+		}
+	}
+	return nil
+}
+
+// parseValues turns a member into values and their weights. Objects become children of b.
+func (p *parser) parseValues(
+	b *Blueprint, m *member, k, path string, valueCounter *int,
+) ([]Value, []float64, error) {
+	switch value := m.value.(type) {
 	case string:
-		return []string{value}, nil
-	case map[string]interface{}:
+		if str, weight, err := splitWeight(value); err != nil {
+			return nil, nil, &PositionError{p.at(m.line, path), err}
+		} else if value, err := p.parseString(str, m.line, path); err != nil {
+			return nil, nil, err
+		} else {
+			return []Value{value}, []float64{weight}, nil
+		}
+	case float64, bool:
+		return []Value{{value}}, []float64{1}, nil
+	case *object:
 		str := fmt.Sprintf("*%v%v", k, *valueCounter)
 		*valueCounter++
-		if child, err := parseRaw(value, b); err != nil {
-			return nil, err
+		if child, err := p.parseObject(value, b, path); err != nil {
+			return nil, nil, err
 		} else {
 			b.children[str] = child
 		}
-		return []string{str}, nil
-	case []interface{}:
-		values := make([]string, 0)
-		for _, elem := range value {
-			if strs, err := b.parseValues(elem, k, valueCounter); err != nil {
-				return nil, err
+		return []Value{{str}}, []float64{1}, nil
+	case []*member:
+		values, weights := make([]Value, 0), make([]float64, 0)
+		for i, elem := range value {
+			if tuple, ok := asTuple(elem); ok {
+				values = append(values, tuple)
+				weights = append(weights, 1)
+			} else if vs, ws, err := p.parseValues(b, elem, k, join(path, strconv.Itoa(i)), valueCounter); err != nil {
+				return nil, nil, err
 			} else {
-				values = append(values, strs...)
+				values = append(values, vs...)
+				weights = append(weights, ws...)
 			}
 		}
-		return values, nil
+		return values, weights, nil
 	default:
-		return nil, fmt.Errorf("%w: '%v' is not valid type for a property", ErrInvalidScript, raw)
+		return nil, nil, &PositionError{p.at(m.line, path),
+			fmt.Errorf("%w: '%v' is not valid type for a property", ErrInvalidScript, m.value)}
+	}
+}
+
+// parseString turns a string into a Value, which is a Distribution if the string describes one.
+func (p *parser) parseString(str string, line int, path string) (Value, error) {
+	if d, ok, err := parseDistribution(str); err != nil {
+		return Value{}, &PositionError{p.at(line, path), err}
+	} else if ok {
+		return Value{d}, nil
+	} else {
+		return Value{str}, nil
+	}
+}
+
+// asTuple checks if a member is a non-empty array of numbers and booleans and converts it into a Value.
+func asTuple(m *member) (Value, bool) {
+	elems, ok := m.value.([]*member)
+	if !ok || len(elems) == 0 {
+		return Value{}, false
+	}
+
+	tuple := make([]Value, len(elems))
+	for i, elem := range elems {
+		switch elem.value.(type) {
+		case float64, bool:
+			tuple[i] = Value{elem.value}
+		default:
+			return Value{}, false
+		}
+	}
+	return Value{tuple}, true
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	} else {
+		return path + "/" + key
+	}
+}
+
+// splitWeight separates the weight suffix from a value.
+func splitWeight(value string) (string, float64, error) {
+	i := strings.LastIndex(value, "*")
+	if i <= 0 {
+		return value, 1, nil
+	} else if weight, err := strconv.ParseFloat(value[i+1:], 64); err != nil {
+		return value, 1, nil
+	} else if !(weight > 0) || math.IsInf(weight, 0) {
+		return "", 0, fmt.Errorf("%w: weight of '%v' must be positive", ErrInvalidScript, value)
+	} else {
+		return value[:i], weight, nil
 	}
 }
 
 // Values returnes the values associated with a property.
-// If the queried Blueprint doesn't contain that property, the parents are called recursively.
+// If the queried Blueprint doesn't contain that property, the included blueprints and the parents are called
+// recursively.
 func (b *Blueprint) Values(property string) []string {
-	if values, ok := b.values[property]; ok {
-		return values
-	} else if b.parent != nil {
-		return b.parent.Values(property)
+	if scope := b.scope(property); scope != nil {
+		return scope.values[property]
+	} else {
+		return nil
+	}
+}
+
+// Weights returns the weights of the values associated with a property.
+// The result has the same length as the one of Values(). Values without an explicit weight have weight 1.
+func (b *Blueprint) Weights(property string) []float64 {
+	if scope := b.scope(property); scope != nil {
+		return scope.weights[property]
 	} else {
 		return nil
 	}
 }
 
+// Position returns where the values that Values() returns for a property are defined. ok is false if the property
+// doesn't exist.
+func (b *Blueprint) Position(property string) (pos Position, ok bool) {
+	if scope := b.scope(property); scope != nil {
+		return scope.positions[property], true
+	} else {
+		return Position{}, false
+	}
+}
+
+// Location returns where the Blueprint itself is defined.
+func (b *Blueprint) Location() Position {
+	return b.location
+}
+
+// scope returns the blueprint that defines the values of a property as seen from b or nil if there is none.
+func (b *Blueprint) scope(property string) *Blueprint {
+	if _, ok := b.values[property]; ok {
+		return b
+	}
+	if scope := b.includedScope(property); scope != nil {
+		return scope
+	}
+	if b.parent != nil {
+		return b.parent.scope(property)
+	}
+	return nil
+}
+
+// includedScope searches the included blueprints, including the ones they include, but not their parents.
+func (b *Blueprint) includedScope(property string) *Blueprint {
+	for _, included := range b.includes {
+		if _, ok := included.values[property]; ok {
+			return included
+		} else if scope := included.includedScope(property); scope != nil {
+			return scope
+		}
+	}
+	return nil
+}
+
 // Properties returns all the properties defined in the Blueprint, that have values as data.
 // Since Values() additionally does recursive calls, the list returned here doesn't match the properties that are
-// accessible through Values().
+// accessible through Values(). ResolvedProperties() lists those.
 func (b *Blueprint) Properties() []string {
 	properties := make([]string, len(b.values))
 	i := 0
@@ -117,20 +411,38 @@ func (b *Blueprint) Properties() []string {
 }
 
 // Child returnes the child associated with a property.
-// If the queried Blueprint doesn't contain that property, the parents are called recursively.
+// If the queried Blueprint doesn't contain that property, the included blueprints and the parents are called
+// recursively.
 func (b *Blueprint) Child(property string) *Blueprint {
 	if child, ok := b.children[property]; ok {
 		return child
-	} else if b.parent != nil {
+	}
+	for _, included := range b.includes {
+		if child := included.includedChild(property); child != nil {
+			return child
+		}
+	}
+	if b.parent != nil {
 		return b.parent.Child(property)
-	} else {
-		return nil
 	}
+	return nil
+}
+
+func (b *Blueprint) includedChild(property string) *Blueprint {
+	if child, ok := b.children[property]; ok {
+		return child
+	}
+	for _, included := range b.includes {
+		if child := included.includedChild(property); child != nil {
+			return child
+		}
+	}
+	return nil
 }
 
 // Children returns all the names of the children defined in the Blueprint.
 // Since Child() additionally does recursive calls, the list returned here doesn't match the properties that are
-// accessible through Child().
+// accessible through Child(). ResolvedChildren() lists those.
 func (b *Blueprint) Children() []string {
 	children := make([]string, len(b.children))
 	i := 0
@@ -1,8 +1,13 @@
 package blueprint_test
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/nilsbu/arch/pkg/blueprint"
@@ -38,13 +43,32 @@ func TestBlueprint(t *testing.T) {
 			false, nil, nil, nil,
 		},
 		{
-			"bool not allowed as value",
+			"bool as value",
 			`{"k":true}`,
-			false, nil, nil, nil,
+			true,
+			[]string{"k"},
+			[]string{},
+			[]ksvs{{[]string{"k"}, []string{"true"}}},
+		},
+		{
+			"number as value",
+			`{"k":69.5}`,
+			true,
+			[]string{"k"},
+			[]string{},
+			[]ksvs{{[]string{"k"}, []string{"69.5"}}},
 		},
 		{
-			"int not allowed as value",
-			`{"k":69}`,
+			"tuples in lists of values",
+			`{"k":[[1,2],[true]]}`,
+			true,
+			[]string{"k"},
+			[]string{},
+			[]ksvs{{[]string{"k"}, []string{"[1,2]", "[true]"}}},
+		},
+		{
+			"null not allowed as value",
+			`{"k":null}`,
 			false, nil, nil, nil,
 		},
 		{
@@ -99,12 +123,12 @@ func TestBlueprint(t *testing.T) {
 		},
 		{
 			"error in child",
-			`{"k":{"kk":32}}`,
+			`{"k":{"kk":null}}`,
 			false, nil, nil, nil,
 		},
 		{
 			"error in list",
-			`{"k":["kk",32]}`,
+			`{"k":["kk",null]}`,
 			false, nil, nil, nil,
 		},
 		// now let's test access semantics in more detail
@@ -270,3 +294,348 @@ func TestBlueprintFalseChild(t *testing.T) {
 	}
 
 }
+
+func TestBlueprintWeights(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		json    string
+		ok      bool
+		values  []string
+		weights []float64
+	}{
+		{"no weights", `{"k":["a","b"]}`, true, []string{"a", "b"}, []float64{1, 1}},
+		{"weighted", `{"k":["a*3","b","c*0.5"]}`, true, []string{"a", "b", "c"}, []float64{3, 1, .5}},
+		{"star without number", `{"k":["a*b","c"]}`, true, []string{"a*b", "c"}, []float64{1, 1}},
+		{"last star counts", `{"k":["a*2*4","c"]}`, true, []string{"a*2", "c"}, []float64{4, 1}},
+		{"single value", `{"k":"a*2"}`, true, []string{"a"}, []float64{2}},
+		{"single element", `{"k":["a*2"]}`, true, []string{"a"}, []float64{2}},
+		{"explicit weight keeps star", `{"k":"a*2*1"}`, true, []string{"a*2"}, []float64{1}},
+		{"zero weight of single value", `{"k":"a*0"}`, false, nil, nil},
+		{"block isn't weighted", `{"k":{}}`, true, []string{"*k0"}, []float64{1}},
+		{"zero weight", `{"k":["a*0","c"]}`, false, nil, nil},
+		{"negative weight", `{"k":["a*-1","c"]}`, false, nil, nil},
+		{"NaN weight", `{"k":["a*NaN","c"]}`, false, nil, nil},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bp, err := blueprint.Parse([]byte(c.json))
+			if c.ok && err != nil {
+				t.Error("didn't expect error:", err)
+			} else if !c.ok && err == nil {
+				t.Error("expected error but none occurred")
+			} else if c.ok {
+				if !reflect.DeepEqual(c.values, bp.Values("k")) {
+					t.Errorf("expected values %v but got %v", c.values, bp.Values("k"))
+				}
+				if !reflect.DeepEqual(c.weights, bp.Weights("k")) {
+					t.Errorf("expected weights %v but got %v", c.weights, bp.Weights("k"))
+				}
+			}
+		})
+	}
+}
+
+func TestBlueprintWeightsOfParent(t *testing.T) {
+	bp, _ := blueprint.Parse([]byte(`{"k":["a*2","b"],"c":{}}`))
+	if weights := bp.Child("*c0").Weights("k"); !reflect.DeepEqual([]float64{2, 1}, weights) {
+		t.Errorf("expected weights [2 1] but got %v", weights)
+	}
+	if weights := bp.Weights("x"); weights != nil {
+		t.Errorf("expected nil but got %v", weights)
+	}
+}
+
+// writeFiles creates files in a temporary directory and returns the directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParseFileIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.json":      `{"@include":["lib/rooms.json","lib/extra.json"],"a":"main","Room":"own"}`,
+		"lib/rooms.json": `{"@include":"base.json","Room":"lib","Hall":{"k":"x"},"b":"rooms"}`,
+		"lib/extra.json": `{"b":"extra","c":"extra"}`,
+		"lib/base.json":  `{"c":"base","d":"base"}`,
+	})
+
+	bp, err := blueprint.ParseFile(filepath.Join(dir, "main.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, c := range []struct {
+		property string
+		values   []string
+	}{
+		{"a", []string{"main"}},
+		{"Room", []string{"own"}},
+		{"b", []string{"rooms"}},
+		{"c", []string{"base"}},
+		{"d", []string{"base"}},
+		{"Hall", []string{"*Hall0"}},
+		{blueprint.Include, nil},
+	} {
+		if values := bp.Values(c.property); !reflect.DeepEqual(c.values, values) {
+			t.Errorf("wrong values for '%v':\nexpect: %v\nactual: %v", c.property, c.values, values)
+		}
+	}
+
+	hall := bp.Child("*Hall0")
+	if hall == nil {
+		t.Fatal("included child not found")
+	}
+	if values := hall.Values("Room"); !reflect.DeepEqual([]string{"lib"}, values) {
+		t.Errorf("child of included blueprint should see its own file, but got %v", values)
+	}
+	if values := hall.Values("a"); values != nil {
+		t.Errorf("child of included blueprint shouldn't see including blueprint, but got %v", values)
+	}
+}
+
+func TestParseFileIncludeErrors(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		files map[string]string
+		err   error
+	}{
+		{
+			"cycle",
+			map[string]string{
+				"main.json": `{"@include":"a.json"}`,
+				"a.json":    `{"@include":"b.json"}`,
+				"b.json":    `{"@include":"a.json"}`,
+			},
+			blueprint.ErrInvalidScript,
+		},
+		{
+			"self",
+			map[string]string{"main.json": `{"x":{"@include":"main.json"}}`},
+			blueprint.ErrInvalidScript,
+		},
+		{
+			"missing file",
+			map[string]string{"main.json": `{"@include":"missing.json"}`},
+			os.ErrNotExist,
+		},
+		{
+			"invalid path",
+			map[string]string{"main.json": `{"@include":{"a":"b"}}`},
+			blueprint.ErrInvalidScript,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := writeFiles(t, c.files)
+			if _, err := blueprint.ParseFile(filepath.Join(dir, "main.json")); !errors.Is(err, c.err) {
+				t.Errorf("expected %v but got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestParseFileSharedInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.json": `{"@include":["a.json","b.json"]}`,
+		"a.json":    `{"@include":"lib.json","a":"a"}`,
+		"b.json":    `{"@include":"lib.json","b":"b"}`,
+		"lib.json":  `{"l":"lib"}`,
+	})
+
+	bp, err := blueprint.ParseFile(filepath.Join(dir, "main.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if values := bp.Values("l"); !reflect.DeepEqual([]string{"lib"}, values) {
+		t.Errorf("expected [lib] but got %v", values)
+	}
+}
+
+func TestParseInclude(t *testing.T) {
+	if _, err := blueprint.Parse([]byte(`{"@include":"lib.json"}`)); !errors.Is(err, blueprint.ErrInvalidScript) {
+		t.Errorf("expected ErrInvalidScript but got %v", err)
+	}
+}
+
+func TestBlueprintPositions(t *testing.T) {
+	bp, err := blueprint.Parse([]byte(`{
+		"a": "x",
+		"b": [
+			"y",
+			{"c": "z"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	child := bp.Child("*b0")
+	for _, c := range []struct {
+		name   string
+		actual blueprint.Position
+		expect blueprint.Position
+	}{
+		{"root", bp.Location(), blueprint.Position{Line: 1}},
+		{"value", position(bp, "a"), blueprint.Position{Line: 2, Path: "a"}},
+		{"list", position(bp, "b"), blueprint.Position{Line: 3, Path: "b"}},
+		{"child", child.Location(), blueprint.Position{Line: 5, Path: "b/1"}},
+		{"property of child", position(child, "c"), blueprint.Position{Line: 5, Path: "b/1/c"}},
+		{"property of parent", position(child, "a"), blueprint.Position{Line: 2, Path: "a"}},
+	} {
+		if c.expect != c.actual {
+			t.Errorf("%v: expected %v but got %v", c.name, c.expect, c.actual)
+		}
+	}
+
+	if _, ok := bp.Position("missing"); ok {
+		t.Error("missing property shouldn't have a position")
+	}
+}
+
+func position(bp *blueprint.Blueprint, property string) blueprint.Position {
+	pos, _ := bp.Position(property)
+	return pos
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		json string
+		err  error
+		pos  blueprint.Position
+	}{
+		{"syntax", "{\n\"a\":\n}", nil, blueprint.Position{Line: 3}},
+		{"invalid type", "{\n\"a\": {\n\"b\": [\"x\", null]}}", blueprint.ErrInvalidScript,
+			blueprint.Position{Line: 3, Path: "a/b/1"}},
+		{"invalid weight", "{\n\"a\": [\"y\",\n\"x*0\"]}", blueprint.ErrInvalidScript,
+			blueprint.Position{Line: 3, Path: "a/1"}},
+		{"no object", "\n[]", blueprint.ErrInvalidScript, blueprint.Position{Line: 2}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := blueprint.Parse([]byte(c.json))
+			var pe *blueprint.PositionError
+			if !errors.As(err, &pe) {
+				t.Fatalf("expected PositionError but got %v", err)
+			}
+			if c.err != nil && !errors.Is(err, c.err) {
+				t.Errorf("expected %v but got %v", c.err, err)
+			}
+			if c.pos != pe.Position {
+				t.Errorf("expected position %v but got %v", c.pos, pe.Position)
+			}
+		})
+	}
+}
+
+func TestParseFileErrorPositions(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.json":  "{\n\"@include\": \"lib/a.json\"\n}",
+		"lib/a.json": "{\n\"x\": null\n}",
+	})
+
+	_, err := blueprint.ParseFile(filepath.Join(dir, "main.json"))
+	expect := fmt.Sprintf("%v:2 at @include: cannot include '%v': %v:2 at x: ",
+		filepath.Join(dir, "main.json"), filepath.Join(dir, "lib/a.json"), filepath.Join(dir, "lib/a.json"))
+	if err == nil || !strings.HasPrefix(err.Error(), expect) {
+		t.Errorf("error should start with %q but was %v", expect, err)
+	}
+}
+
+func TestPositionString(t *testing.T) {
+	for _, c := range []struct {
+		pos    blueprint.Position
+		expect string
+	}{
+		{blueprint.Position{}, ""},
+		{blueprint.Position{File: "a.json"}, "a.json"},
+		{blueprint.Position{File: "a.json", Line: 42, Path: "x/y"}, "a.json:42 at x/y"},
+		{blueprint.Position{Line: 42, Path: "x/y"}, "line 42 at x/y"},
+		{blueprint.Position{Path: "x/y"}, "x/y"},
+	} {
+		if actual := c.pos.String(); c.expect != actual {
+			t.Errorf("expected %q but got %q", c.expect, actual)
+		}
+	}
+}
+
+func TestResolvedProperties(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.json": `{
+			"@include":"lib.json",
+			"a":"main",
+			"b":"main",
+			"Hall":{"a":"hall","Desk":{}}
+		}`,
+		"lib.json": `{"b":"lib","c":"lib","Desk":{}}`,
+	})
+
+	bp, err := blueprint.ParseFile(filepath.Join(dir, "main.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hall := bp.Child("*Hall0")
+	lib := filepath.Join(dir, "lib.json")
+	main := filepath.Join(dir, "main.json")
+
+	type binding struct {
+		property string
+		// own is true if the property is defined in the queried blueprint
+		own      bool
+		file     string
+		line     int
+		shadowed []int
+	}
+	for _, c := range []struct {
+		name     string
+		bp       *blueprint.Blueprint
+		bindings []blueprint.Binding
+		expect   []binding
+	}{
+		{
+			"properties of root",
+			bp, bp.ResolvedProperties(),
+			[]binding{
+				{"Desk", false, lib, 1, nil}, {"Hall", true, main, 5, nil}, {"a", true, main, 3, nil},
+				{"b", true, main, 4, []int{1}}, {"c", false, lib, 1, nil},
+			},
+		},
+		{
+			"children of root",
+			bp, bp.ResolvedChildren(),
+			[]binding{{"*Desk0", false, lib, 1, nil}, {"*Hall0", true, main, 5, nil}},
+		},
+		{
+			"properties of child",
+			hall, hall.ResolvedProperties(),
+			[]binding{
+				{"Desk", true, main, 5, []int{1}}, {"Hall", false, main, 5, nil}, {"a", true, main, 5, []int{3}},
+				{"b", false, main, 4, []int{1}}, {"c", false, lib, 1, nil},
+			},
+		},
+		{
+			"children of child",
+			hall, hall.ResolvedChildren(),
+			[]binding{{"*Desk0", true, main, 5, []int{1}}, {"*Hall0", false, main, 5, nil}},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			actual := make([]binding, len(c.bindings))
+			for i, b := range c.bindings {
+				actual[i] = binding{b.Property, b.Origin == c.bp, b.Position.File, b.Position.Line, nil}
+				for _, pos := range b.Shadowed {
+					actual[i].shadowed = append(actual[i].shadowed, pos.Line)
+				}
+			}
+			if !reflect.DeepEqual(c.expect, actual) {
+				t.Errorf("bindings don't match:\nexpect: %v\nactual: %v", c.expect, actual)
+			}
+		})
+	}
+}
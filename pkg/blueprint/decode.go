@@ -0,0 +1,122 @@
+package blueprint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// An object is a decoded JSON object that remembers where its members are defined.
+type object struct {
+	line int
+	// keys are in the order in which they first appear
+	keys    []string
+	members map[string]*member
+}
+
+// A member is a decoded JSON value and the line on which it starts.
+// value is either string, float64, bool, nil, []*member or *object.
+type member struct {
+	line  int
+	value interface{}
+}
+
+type decoder struct {
+	dec      *json.Decoder
+	newlines []int
+}
+
+// decode parses JSON data that must contain an object. Errors are *PositionErrors that contain the line.
+func decode(data []byte) (*object, error) {
+	d := &decoder{dec: json.NewDecoder(bytes.NewReader(data))}
+	for i, c := range data {
+		if c == '\n' {
+			d.newlines = append(d.newlines, i)
+		}
+	}
+
+	m, err := d.member()
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := m.value.(*object)
+	if !ok {
+		return nil, &PositionError{Position{Line: m.line},
+			fmt.Errorf("%w: content must be an object", ErrInvalidScript)}
+	}
+	if _, err := d.dec.Token(); err != io.EOF {
+		return nil, d.fail(fmt.Errorf("%w: unexpected content after object", ErrInvalidScript))
+	}
+	return obj, nil
+}
+
+// line returns the line on which the last token ended.
+func (d *decoder) line() int {
+	return sort.SearchInts(d.newlines, int(d.dec.InputOffset())) + 1
+}
+
+func (d *decoder) fail(err error) error {
+	offset := int(d.dec.InputOffset())
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		offset = int(se.Offset)
+	}
+	if se != nil || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = fmt.Errorf("cannot parse JSON: %w", err)
+	}
+	return &PositionError{Position{Line: sort.SearchInts(d.newlines, offset) + 1}, err}
+}
+
+func (d *decoder) member() (*member, error) {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return nil, d.fail(err)
+	}
+
+	m := &member{line: d.line()}
+	switch tok {
+	case json.Delim('{'):
+		obj := &object{line: m.line, members: map[string]*member{}}
+		for d.dec.More() {
+			key, err := d.dec.Token()
+			if err != nil {
+				return nil, d.fail(err)
+			}
+			line := d.line()
+			elem, err := d.member()
+			if err != nil {
+				return nil, err
+			}
+			elem.line = line
+
+			k := key.(string)
+			if _, ok := obj.members[k]; !ok {
+				obj.keys = append(obj.keys, k)
+			}
+			obj.members[k] = elem
+		}
+		m.value = obj
+	case json.Delim('['):
+		elems := []*member{}
+		for d.dec.More() {
+			elem, err := d.member()
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, elem)
+		}
+		m.value = elems
+	default:
+		m.value = tok
+		return m, nil
+	}
+
+	// consume the closing delimiter
+	if _, err := d.dec.Token(); err != nil {
+		return nil, d.fail(err)
+	}
+	return m, nil
+}
@@ -0,0 +1,110 @@
+package blueprint
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+)
+
+// A Distribution is a value that stands for a set of numbers, one of which is drawn whenever the blueprint is used.
+// It is written as "range(min,max)" for the whole numbers from min to max or as "uniform(min,max)" for the real
+// numbers in [min, max).
+type Distribution struct {
+	// Integer is true for ranges. Their bounds are whole numbers.
+	Integer  bool
+	Min, Max float64
+}
+
+// maxRange is the largest number of values in a range. They can be counted in an int and are represented exactly.
+var maxRange = math.Min(1<<53, math.MaxInt)
+
+var distribution = regexp.MustCompile(`^\s*(range|uniform)\s*\(\s*([^,\s]+)\s*,\s*([^,\s]+)\s*\)\s*$`)
+
+// parseDistribution checks if a string describes a distribution. If it looks like one but isn't valid, an error is
+// returned.
+func parseDistribution(str string) (Distribution, bool, error) {
+	match := distribution.FindStringSubmatch(str)
+	if match == nil {
+		return Distribution{}, false, nil
+	}
+
+	d := Distribution{Integer: match[1] == "range"}
+	var err error
+	if d.Min, err = strconv.ParseFloat(match[2], 64); err != nil {
+		return d, true, fmt.Errorf("%w: bounds of '%v' must be numbers", ErrInvalidScript, str)
+	} else if d.Max, err = strconv.ParseFloat(match[3], 64); err != nil {
+		return d, true, fmt.Errorf("%w: bounds of '%v' must be numbers", ErrInvalidScript, str)
+	} else if math.IsInf(d.Min, 0) || math.IsInf(d.Max, 0) || d.Min > d.Max {
+		return d, true, fmt.Errorf("%w: bounds of '%v' must be finite and ordered", ErrInvalidScript, str)
+	} else if d.Integer && (d.Min != math.Trunc(d.Min) || d.Max != math.Trunc(d.Max)) {
+		return d, true, fmt.Errorf("%w: bounds of '%v' must be whole numbers", ErrInvalidScript, str)
+	} else if d.Integer && d.Max-d.Min+1 > maxRange {
+		return d, true, fmt.Errorf("%w: '%v' has more than %v values", ErrInvalidScript, str, maxRange)
+	}
+	return d, true, nil
+}
+
+func (d Distribution) String() string {
+	name := "uniform"
+	if d.Integer {
+		name = "range"
+	}
+	return fmt.Sprintf("%v(%v,%v)", name, strconv.FormatFloat(d.Min, 'g', -1, 64),
+		strconv.FormatFloat(d.Max, 'g', -1, 64))
+}
+
+// Count returns the number of values in a range. It is 0 for continuous distributions.
+func (d Distribution) Count() int {
+	if d.Integer {
+		return int(d.Max-d.Min) + 1
+	} else {
+		return 0
+	}
+}
+
+// At returns the i-th value of a range, starting at Min.
+func (d Distribution) At(i int) Value {
+	return Value{d.Min + float64(i)}
+}
+
+// Sample draws a value from the distribution.
+func (d Distribution) Sample(rnd *rand.Rand) Value {
+	if d.Integer {
+		return d.At(rnd.Intn(d.Count()))
+	} else {
+		return Value{d.Min + rnd.Float64()*(d.Max-d.Min)}
+	}
+}
+
+// Distribution returns the value if it is a distribution.
+func (v Value) Distribution() (Distribution, bool) {
+	d, ok := v.data.(Distribution)
+	return d, ok
+}
+
+// With returns a copy of the blueprint in which a property has other values. The copy has the same parent, includes
+// and children, so everything else is resolved as in the original. If the property is defined elsewhere, the copy
+// shadows it. All values have weight 1.
+func (b *Blueprint) With(property string, values []Value) *Blueprint {
+	c := *b
+	c.values = make(map[string][]string, len(b.values)+1)
+	c.typed = make(map[string][]Value, len(b.typed)+1)
+	c.weights = make(map[string][]float64, len(b.weights)+1)
+	for k := range b.values {
+		c.values[k], c.typed[k], c.weights[k] = b.values[k], b.typed[k], b.weights[k]
+	}
+	if _, ok := b.values[property]; !ok {
+		c.keys = append(b.keys[:len(b.keys):len(b.keys)], property)
+	}
+
+	c.typed[property] = values
+	c.values[property] = make([]string, len(values))
+	c.weights[property] = make([]float64, len(values))
+	for i, v := range values {
+		c.values[property][i] = v.String()
+		c.weights[property][i] = 1
+	}
+	return &c
+}
@@ -0,0 +1,121 @@
+package blueprint_test
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/nilsbu/arch/pkg/blueprint"
+)
+
+func TestDistributions(t *testing.T) {
+	for _, c := range []struct {
+		value string
+		ok    bool
+		dist  blueprint.Distribution
+		str   string
+	}{
+		{"range(2,5)", true, blueprint.Distribution{Integer: true, Min: 2, Max: 5}, "range(2,5)"},
+		{" range( -1 , 1 ) ", true, blueprint.Distribution{Integer: true, Min: -1, Max: 1}, "range(-1,1)"},
+		{"uniform(0.2,0.8)", true, blueprint.Distribution{Min: .2, Max: .8}, "uniform(0.2,0.8)"},
+		{"uniform(1,1)", true, blueprint.Distribution{Min: 1, Max: 1}, "uniform(1,1)"},
+		{"range(1.5,3)", false, blueprint.Distribution{}, ""},
+		{"range(3,1)", false, blueprint.Distribution{}, ""},
+		{"range(0,9007199254740991)", true, blueprint.Distribution{Integer: true, Min: 0, Max: 1<<53 - 1},
+			"range(0,9.007199254740991e+15)"},
+		{"range(0,1e19)", false, blueprint.Distribution{}, ""},
+		{"range(-1e19,1e19)", false, blueprint.Distribution{}, ""},
+		{"uniform(a,1)", false, blueprint.Distribution{}, ""},
+		{"uniform(0,inf)", false, blueprint.Distribution{}, ""},
+	} {
+		t.Run(c.value, func(t *testing.T) {
+			bp, err := blueprint.Parse([]byte(`{"x":"` + c.value + `"}`))
+			if !c.ok {
+				if !errors.Is(err, blueprint.ErrInvalidScript) {
+					t.Fatalf("expected ErrInvalidScript but got %v", err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			v := bp.TypedValues("x")[0]
+			if d, ok := v.Distribution(); !ok {
+				t.Fatal("value isn't a distribution")
+			} else if d != c.dist {
+				t.Errorf("expected %v but got %v", c.dist, d)
+			}
+			if v.String() != c.str {
+				t.Errorf("expected '%v' but got '%v'", c.str, v.String())
+			}
+			if _, err := bp.Float("x"); !errors.Is(err, blueprint.ErrWrongType) {
+				t.Errorf("a distribution must not be read as a number, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestDistributionSample(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	r := blueprint.Distribution{Integer: true, Min: 2, Max: 4}
+	seen := map[int]bool{}
+	for i := 0; i < 100; i++ {
+		if n, err := r.Sample(rnd).Int(); err != nil {
+			t.Fatal("unexpected error:", err)
+		} else if n < 2 || n > 4 {
+			t.Fatalf("%v is out of range", n)
+		} else {
+			seen[n] = true
+		}
+	}
+	if len(seen) != r.Count() {
+		t.Errorf("expected all %v values to be drawn but got %v", r.Count(), seen)
+	}
+
+	u := blueprint.Distribution{Min: .2, Max: .8}
+	for i := 0; i < 100; i++ {
+		if f, _ := u.Sample(rnd).Float(); f < .2 || f >= .8 {
+			t.Fatalf("%v is out of range", f)
+		}
+	}
+	if u.Count() != 0 {
+		t.Errorf("continuous distribution must have count 0 but has %v", u.Count())
+	}
+}
+
+func TestWith(t *testing.T) {
+	bp, err := blueprint.Parse([]byte(`{"w":"range(1,3)","a":{"x":"y"}}`))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	a := bp.Child("*a0")
+	d, _ := bp.TypedValues("w")[0].Distribution()
+
+	with := a.With("w", []blueprint.Value{d.At(1)})
+	if n, err := with.Int("w"); err != nil || n != 2 {
+		t.Errorf("expected 2 but got %v, %v", n, err)
+	}
+	if values := with.Values("x"); !reflect.DeepEqual([]string{"y"}, values) {
+		t.Errorf("other properties must be unchanged, but got %v", values)
+	}
+	if values := a.Values("w"); !reflect.DeepEqual([]string{"range(1,3)"}, values) {
+		t.Errorf("original must be unchanged, but got %v", values)
+	}
+}
+
+func TestMarshalDistributions(t *testing.T) {
+	bp, err := blueprint.Parse([]byte(`{"w":["range(1,3)","uniform(0.5,1)*2"]}`))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	data, err := blueprint.Marshal(bp)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	actual, err := blueprint.Parse(data)
+	if err != nil {
+		t.Fatalf("cannot parse marshaled blueprint: %v\n%s", err, data)
+	}
+	compare(t, bp, actual)
+}
@@ -0,0 +1,376 @@
+package blueprint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Encode writes a blueprint in the given format. Parsing the result yields an identical blueprint. Properties keep
+// the order in which they were defined as far as the format allows, children are written inline and includes are
+// written as they were read, so they are only found if the result is placed next to the original file.
+// Templates are written as they were defined: Instances keep "@template" and their arguments and repetitions keep
+// "@repeat", although the blueprint contains them expanded. Properties that were written as lists stay lists.
+func Encode(bp *Blueprint, format Format) ([]byte, error) {
+	obj := bp.object()
+	buf := &bytes.Buffer{}
+	var err error
+	switch format {
+	case JSON:
+		err = writeJSON(buf, &member{value: obj}, "")
+		buf.WriteString("\n")
+	case YAML:
+		err = writeYAML(buf, obj)
+	case TOML:
+		err = writeTOML(buf, obj, nil)
+	default:
+		err = fmt.Errorf("%w: %v", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Marshal writes a blueprint as canonical JSON. Like Encode, it writes children inline where they are used and keeps
+// the weights of values. Properties are sorted by name and the layout is fixed, so blueprints that only differ in
+// formatting and in the order of their properties are written identically. Like Encode, it writes instances of
+// templates and repetitions as they were defined.
+func Marshal(bp *Blueprint) ([]byte, error) {
+	obj := bp.object()
+	obj.sort()
+	buf := &bytes.Buffer{}
+	if err := writeJSON(buf, &member{value: obj}, ""); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// object turns a blueprint back into the structure it was parsed from.
+func (b *Blueprint) object() *object {
+	if b.source != nil {
+		return b.source.object()
+	}
+
+	obj := &object{members: map[string]*member{}}
+	if len(b.included) == 1 {
+		obj.add(Include, &member{value: b.included[0]})
+	} else if len(b.included) > 1 {
+		elems := make([]*member, len(b.included))
+		for i, path := range b.included {
+			elems[i] = &member{value: path}
+		}
+		obj.add(Include, &member{value: elems})
+	}
+
+	for _, k := range b.keys {
+		values, weights := b.typed[k], b.weights[k]
+		elems := make([]*member, len(values))
+		for i, v := range values {
+			elems[i] = b.member(v, weights[i])
+		}
+		if len(values) == 1 && !isTuple(values[0]) && !b.lists[k] {
+			obj.add(k, elems[0])
+		} else {
+			obj.add(k, &member{value: elems})
+		}
+	}
+	return obj
+}
+
+// member turns a value into a member. Strings are written with their weight if it isn't 1 or if the value would be
+// taken for a weight otherwise.
+func (b *Blueprint) member(v Value, weight float64) *member {
+	switch data := v.data.(type) {
+	case string:
+		if child, ok := b.children[data]; ok {
+			return &member{value: child.object()}
+		} else if weight != 1 || looksWeighted(data) {
+			return &member{value: data + "*" + strconv.FormatFloat(weight, 'g', -1, 64)}
+		} else {
+			return &member{value: data}
+		}
+	case Distribution:
+		return b.member(Value{data.String()}, weight)
+	case []Value:
+		elems := make([]*member, len(data))
+		for i, elem := range data {
+			elems[i] = &member{value: elem.data}
+		}
+		return &member{value: elems}
+	default:
+		return &member{value: data}
+	}
+}
+
+// looksWeighted checks if a string ends in something that is parsed as a weight.
+func looksWeighted(str string) bool {
+	value, _, err := splitWeight(str)
+	return value != str || err != nil
+}
+
+func isTuple(v Value) bool {
+	_, ok := v.data.([]Value)
+	return ok
+}
+
+func (o *object) add(key string, m *member) {
+	o.keys = append(o.keys, key)
+	o.members[key] = m
+}
+
+// sort orders the keys of an object and the ones nested in it.
+func (o *object) sort() {
+	sort.Strings(o.keys)
+	for _, m := range o.members {
+		m.sort()
+	}
+}
+
+func (m *member) sort() {
+	switch value := m.value.(type) {
+	case *object:
+		value.sort()
+	case []*member:
+		for _, elem := range value {
+			elem.sort()
+		}
+	}
+}
+
+// inline checks if a list only contains values and tuples.
+func inline(elems []*member) bool {
+	for _, elem := range elems {
+		switch value := elem.value.(type) {
+		case *object:
+			return false
+		case []*member:
+			if !inline(value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func writeJSON(buf *bytes.Buffer, m *member, indent string) error {
+	switch value := m.value.(type) {
+	case *object:
+		if len(value.keys) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{")
+		for i, k := range value.keys {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n" + indent + "    " + quote(k) + ": ")
+			if err := writeJSON(buf, value.members[k], indent+"    "); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + "}")
+	case []*member:
+		multiline := !inline(value)
+		buf.WriteString("[")
+		for i, elem := range value {
+			if i > 0 {
+				buf.WriteString(",")
+				if !multiline {
+					buf.WriteString(" ")
+				}
+			}
+			if multiline {
+				buf.WriteString("\n" + indent + "    ")
+			}
+			if err := writeJSON(buf, elem, indent+"    "); err != nil {
+				return err
+			}
+		}
+		if multiline {
+			buf.WriteString("\n" + indent)
+		}
+		buf.WriteString("]")
+	case string:
+		buf.WriteString(quote(value))
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("%w: %v cannot be written in JSON", ErrInvalidScript, value)
+		}
+		buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	case bool:
+		buf.WriteString(strconv.FormatBool(value))
+	}
+	return nil
+}
+
+// quote writes a string as it is written in JSON. The result is a valid string in TOML as well.
+func quote(str string) string {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(str)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func writeYAML(buf *bytes.Buffer, obj *object) error {
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(yamlNode(&member{value: obj})); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func yamlNode(m *member) *yaml.Node {
+	switch value := m.value.(type) {
+	case *object:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		for _, k := range value.keys {
+			n.Content = append(n.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, yamlNode(value.members[k]))
+		}
+		return n
+	case []*member:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		if inline(value) {
+			n.Style = yaml.FlowStyle
+		}
+		for _, elem := range value {
+			n.Content = append(n.Content, yamlNode(elem))
+		}
+		return n
+	case float64:
+		// numbers and booleans are plain scalars, so their tags are resolved when they are read
+		if math.IsNaN(value) {
+			return &yaml.Node{Kind: yaml.ScalarNode, Value: ".nan"}
+		} else if math.IsInf(value, 1) {
+			return &yaml.Node{Kind: yaml.ScalarNode, Value: ".inf"}
+		} else if math.IsInf(value, -1) {
+			return &yaml.Node{Kind: yaml.ScalarNode, Value: "-.inf"}
+		} else {
+			return &yaml.Node{Kind: yaml.ScalarNode, Value: strconv.FormatFloat(value, 'g', -1, 64)}
+		}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: strconv.FormatBool(value)}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(value)}
+	}
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if bareKey.MatchString(key) {
+		return key
+	}
+	return quote(key)
+}
+
+// writeTOML writes the members of an object that is reached through path. Objects and lists of objects become tables
+// and arrays of tables, which have to follow the other members.
+func writeTOML(buf *bytes.Buffer, obj *object, path []string) error {
+	var tables []string
+	for _, k := range obj.keys {
+		switch value := obj.members[k].value.(type) {
+		case *object:
+			tables = append(tables, k)
+			continue
+		case []*member:
+			if tableArray(value) {
+				tables = append(tables, k)
+				continue
+			}
+		}
+
+		buf.WriteString(tomlKey(k) + " = ")
+		if err := writeTOMLValue(buf, obj.members[k]); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+	}
+
+	for _, k := range tables {
+		sub := append(path[:len(path):len(path)], tomlKey(k))
+		header := strings.Join(sub, ".")
+		switch value := obj.members[k].value.(type) {
+		case *object:
+			buf.WriteString("\n[" + header + "]\n")
+			if err := writeTOML(buf, value, sub); err != nil {
+				return err
+			}
+		case []*member:
+			for _, elem := range value {
+				buf.WriteString("\n[[" + header + "]]\n")
+				if err := writeTOML(buf, elem.value.(*object), sub); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// tableArray checks if a list can be written as an array of tables, i.e. if it only contains objects.
+func tableArray(elems []*member) bool {
+	for _, elem := range elems {
+		if _, ok := elem.value.(*object); !ok {
+			return false
+		}
+	}
+	return len(elems) > 0
+}
+
+func writeTOMLValue(buf *bytes.Buffer, m *member) error {
+	switch value := m.value.(type) {
+	case *object:
+		buf.WriteString("{")
+		for i, k := range value.keys {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(" " + tomlKey(k) + " = ")
+			if err := writeTOMLValue(buf, value.members[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(" }")
+	case []*member:
+		buf.WriteString("[")
+		for i, elem := range value {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if err := writeTOMLValue(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+	case string:
+		buf.WriteString(quote(value))
+	case float64:
+		if math.IsNaN(value) {
+			buf.WriteString("nan")
+		} else if math.IsInf(value, 1) {
+			buf.WriteString("inf")
+		} else if math.IsInf(value, -1) {
+			buf.WriteString("-inf")
+		} else if value == math.Trunc(value) && math.Abs(value) < 1<<53 {
+			buf.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+		} else {
+			buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+		}
+	case bool:
+		buf.WriteString(strconv.FormatBool(value))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package blueprint
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnknownFormat is returned when the format of a file cannot be determined from its extension.
+var ErrUnknownFormat = errors.New("unknown blueprint format")
+
+// A Format is a file format in which blueprints can be written. All formats describe the same structure: an object
+// whose members are values, lists of values or objects, which become child blueprints. Blueprints that are written in
+// different formats but describe the same structure are identical.
+type Format int
+
+const (
+	JSON Format = iota
+	YAML
+	TOML
+)
+
+var extensions = map[string]Format{
+	".json": JSON,
+	".yaml": YAML,
+	".yml":  YAML,
+	".toml": TOML,
+}
+
+func (f Format) String() string {
+	switch f {
+	case JSON:
+		return "JSON"
+	case YAML:
+		return "YAML"
+	case TOML:
+		return "TOML"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// FormatOf returns the format of a file based on its extension: .json, .yaml, .yml or .toml.
+func FormatOf(path string) (Format, error) {
+	if f, ok := extensions[strings.ToLower(filepath.Ext(path))]; ok {
+		return f, nil
+	} else {
+		return 0, fmt.Errorf("%w: cannot tell format of '%v'", ErrUnknownFormat, path)
+	}
+}
+
+// FormatByName returns the format with the given name, e.g. "yaml". The name is case-insensitive.
+func FormatByName(name string) (Format, error) {
+	for _, f := range []Format{JSON, YAML, TOML} {
+		if strings.EqualFold(name, f.String()) {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: '%v'", ErrUnknownFormat, name)
+}
+
+// decodeFormat decodes data of any format into an object.
+func decodeFormat(data []byte, format Format) (*object, error) {
+	switch format {
+	case JSON:
+		return decode(data)
+	case YAML:
+		return decodeYAML(data)
+	case TOML:
+		return decodeTOML(data)
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnknownFormat, format)
+	}
+}
@@ -0,0 +1,276 @@
+package blueprint_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/nilsbu/arch/pkg/blueprint"
+)
+
+const formatsJSON = `{
+	"@rule": "House",
+	"rect": [0, 0, 80, 40],
+	"sizes": [[2, 4], [3, 2]],
+	"flag": true,
+	"ratio": 0.25,
+	"Rooms": ["Room*2", {"@rule": "Room"}],
+	"Hall": {"@rule": "Frame", "content": {"@rule": "NOP", "x": "y"}},
+	"Line": [{"@rule": "A"}, {"@rule": "B"}]
+}`
+
+const formatsYAML = `# comments are allowed
+"@rule": House
+rect: [0, 0, 80, 40]
+sizes:
+  - [2, 4]
+  - [3, 2]
+flag: true
+ratio: 0.25
+Rooms:
+  - Room*2
+  - "@rule": Room
+Hall:
+  "@rule": Frame
+  content: &nop
+    "@rule": NOP
+    x: "y"
+Line:
+  - {"@rule": A}
+  - {"@rule": B}
+`
+
+const formatsTOML = `# comments are allowed
+"@rule" = "House"
+rect = [0, 0, 80, 40]
+sizes = [[2, 4], [3, 2]]
+flag = true
+ratio = 0.25
+Rooms = ["Room*2", {"@rule" = "Room"}]
+
+[Hall]
+"@rule" = "Frame"
+content."@rule" = "NOP"
+content.x = "y"
+
+[[Line]]
+"@rule" = "A"
+
+[[Line]]
+"@rule" = "B"
+`
+
+func TestFormatOf(t *testing.T) {
+	for _, c := range []struct {
+		path   string
+		format blueprint.Format
+		ok     bool
+	}{
+		{"a.json", blueprint.JSON, true},
+		{"dir/a.yaml", blueprint.YAML, true},
+		{"a.YML", blueprint.YAML, true},
+		{"a.toml", blueprint.TOML, true},
+		{"a.txt", 0, false},
+		{"json", 0, false},
+	} {
+		t.Run(c.path, func(t *testing.T) {
+			format, err := blueprint.FormatOf(c.path)
+			if !c.ok {
+				if !errors.Is(err, blueprint.ErrUnknownFormat) {
+					t.Fatalf("expected ErrUnknownFormat but got %v", err)
+				}
+			} else if err != nil {
+				t.Fatal("unexpected error:", err)
+			} else if format != c.format {
+				t.Errorf("expected %v but got %v", c.format, format)
+			}
+		})
+	}
+}
+
+func TestFormatByName(t *testing.T) {
+	if f, err := blueprint.FormatByName("yaml"); err != nil || f != blueprint.YAML {
+		t.Errorf("expected YAML but got %v, %v", f, err)
+	}
+	if _, err := blueprint.FormatByName("xml"); !errors.Is(err, blueprint.ErrUnknownFormat) {
+		t.Errorf("expected ErrUnknownFormat but got %v", err)
+	}
+}
+
+func TestFormatsAgree(t *testing.T) {
+	expect, err := blueprint.ParseAs([]byte(formatsJSON), blueprint.JSON)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, c := range []struct {
+		format blueprint.Format
+		data   string
+	}{
+		{blueprint.YAML, formatsYAML},
+		{blueprint.TOML, formatsTOML},
+	} {
+		t.Run(c.format.String(), func(t *testing.T) {
+			bp, err := blueprint.ParseAs([]byte(c.data), c.format)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			compare(t, expect, bp)
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	bp, err := blueprint.Parse([]byte(formatsJSON))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, format := range []blueprint.Format{blueprint.JSON, blueprint.YAML, blueprint.TOML} {
+		t.Run(format.String(), func(t *testing.T) {
+			data, err := blueprint.Encode(bp, format)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			actual, err := blueprint.ParseAs(data, format)
+			if err != nil {
+				t.Fatalf("cannot parse encoded blueprint: %v\n%s", err, data)
+			}
+			compare(t, bp, actual)
+		})
+	}
+
+	if _, err := blueprint.Encode(bp, blueprint.Format(-1)); !errors.Is(err, blueprint.ErrUnknownFormat) {
+		t.Errorf("expected ErrUnknownFormat but got %v", err)
+	}
+}
+
+func TestEncodeSingleTuple(t *testing.T) {
+	bp, err := blueprint.Parse([]byte(`{"sizes":[[2,4]],"rect":[1,2]}`))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	data, err := blueprint.Encode(bp, blueprint.JSON)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if expect := "{\n    \"sizes\": [[2, 4]],\n    \"rect\": [1, 2]\n}\n"; string(data) != expect {
+		t.Errorf("wrong encoding:\nexpect: %v\nactual: %v", expect, string(data))
+	}
+}
+
+func TestTOMLPositions(t *testing.T) {
+	bp, err := blueprint.ParseAs([]byte("a = 1\n\nb = [\n  \"x\",\n]\n\n[C]\nd = 2\n"), blueprint.TOML)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	for _, c := range []struct {
+		bp   *blueprint.Blueprint
+		key  string
+		line int
+	}{
+		{bp, "a", 1},
+		{bp, "b", 3},
+		{bp, "C", 7},
+		{bp.Child("*C0"), "d", 8},
+	} {
+		if pos, ok := c.bp.Position(c.key); !ok || pos.Line != c.line {
+			t.Errorf("expected '%v' on line %v but got %v", c.key, c.line, pos)
+		}
+	}
+}
+
+func TestParseAsErrors(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		format blueprint.Format
+		data   string
+		err    error
+		line   int
+	}{
+		{"YAML syntax", blueprint.YAML, "a: b\nc: d\n  e: f\n", nil, 3},
+		{"YAML list", blueprint.YAML, "- a\n- b\n", blueprint.ErrInvalidScript, 1},
+		{"YAML empty", blueprint.YAML, "", blueprint.ErrInvalidScript, 1},
+		{"YAML null", blueprint.YAML, "a: b\nc: null\n", blueprint.ErrInvalidScript, 2},
+		{"YAML timestamp", blueprint.YAML, "a: 2001-12-14\n", blueprint.ErrInvalidScript, 1},
+		{"TOML syntax", blueprint.TOML, "a = \"b\"\nc = \n", nil, 2},
+		{"TOML date", blueprint.TOML, "a = \"b\"\nc = 1979-05-27\n", blueprint.ErrInvalidScript, 2},
+		{"TOML table over value", blueprint.TOML, "a = \"b\"\n[a]\nc = \"d\"\n", blueprint.ErrInvalidScript, 2},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := blueprint.ParseAs([]byte(c.data), c.format)
+			var pe *blueprint.PositionError
+			if err == nil {
+				t.Fatal("expected error but none occurred")
+			} else if c.err != nil && !errors.Is(err, c.err) {
+				t.Errorf("expected %v but got %v", c.err, err)
+			} else if !errors.As(err, &pe) {
+				t.Errorf("expected *PositionError but got %v", err)
+			} else if pe.Position.Line != c.line {
+				t.Errorf("expected error on line %v but got %v", c.line, err)
+			}
+		})
+	}
+}
+
+func TestParseFileFormats(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.yaml":  "\"@include\": [lib.toml, more.json]\na: main\n",
+		"lib.toml":   "b = \"lib\"\n\n[Hall]\nk = \"x\"\n",
+		"more.json":  `{"c":"more"}`,
+		"other.conf": `{"a":"b"}`,
+	})
+
+	bp, err := blueprint.ParseFile(filepath.Join(dir, "main.yaml"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	for property, expect := range map[string][]string{
+		"a":    {"main"},
+		"b":    {"lib"},
+		"c":    {"more"},
+		"Hall": {"*Hall0"},
+	} {
+		if values := bp.Values(property); !reflect.DeepEqual(expect, values) {
+			t.Errorf("wrong values for '%v':\nexpect: %v\nactual: %v", property, expect, values)
+		}
+	}
+
+	if _, err := blueprint.ParseFile(filepath.Join(dir, "other.conf")); !errors.Is(err, blueprint.ErrUnknownFormat) {
+		t.Errorf("expected ErrUnknownFormat but got %v", err)
+	}
+}
+
+// compare checks that two blueprints have the same properties, values and children.
+func compare(t *testing.T, expect, actual *blueprint.Blueprint) {
+	t.Helper()
+	ep, ap := expect.Properties(), actual.Properties()
+	if !reflect.DeepEqual(sorted(ep), sorted(ap)) {
+		t.Fatalf("different properties:\nexpect: %v\nactual: %v", ep, ap)
+	}
+	for _, p := range ep {
+		ev, av := expect.TypedValues(p), actual.TypedValues(p)
+		if !reflect.DeepEqual(ev, av) {
+			t.Errorf("different values for '%v':\nexpect: %v\nactual: %v", p, ev, av)
+		}
+		if ew, aw := expect.Weights(p), actual.Weights(p); !reflect.DeepEqual(ew, aw) {
+			t.Errorf("different weights for '%v':\nexpect: %v\nactual: %v", p, ew, aw)
+		}
+	}
+
+	ec, ac := expect.Children(), actual.Children()
+	if !reflect.DeepEqual(sorted(ec), sorted(ac)) {
+		t.Fatalf("different children:\nexpect: %v\nactual: %v", ec, ac)
+	}
+	for _, c := range ec {
+		compare(t, expect.Child(c), actual.Child(c))
+	}
+}
+
+func sorted(strs []string) []string {
+	out := append([]string{}, strs...)
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,182 @@
+package blueprint_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nilsbu/arch/pkg/blueprint"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestMarshalGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			bp, err := blueprint.ParseFile(path)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			actual, err := blueprint.Marshal(bp)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			golden := strings.TrimSuffix(path, ".json") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, actual, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expect, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(expect, actual) {
+				t.Errorf("output differs from %v:\nexpect:\n%s\nactual:\n%s", golden, expect, actual)
+			}
+		})
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		json string
+	}{
+		{"empty", `{}`},
+		{"values", `{"a":"b","c":["d*2","e"],"n":[1,2.5],"t":[[1,true]],"b":false,"x":[]}`},
+		{"children", `{"@":"A","c":{"@":"B","d":[{"@":"C"},"D",{"@":"E","f":{"g":"h"}}]},"D":{"@":"D"}}`},
+		{"data that looks weighted", `{"a":"b*2","j":"k*2*1","c":["d*2*1","e*0*1","f*x"],"g":["h*2*3","i"]}`},
+		{"escaping", `{"a":"\"<b>\"\n\t\\","@ key":"x"}`},
+		{"formats", formatsJSON},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bp, err := blueprint.Parse([]byte(c.json))
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			data, err := blueprint.Marshal(bp)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			actual, err := blueprint.Parse(data)
+			if err != nil {
+				t.Fatalf("cannot parse marshaled blueprint: %v\n%s", err, data)
+			}
+			compare(t, bp, actual)
+
+			if again, err := blueprint.Marshal(actual); err != nil {
+				t.Fatal("unexpected error:", err)
+			} else if !bytes.Equal(data, again) {
+				t.Errorf("marshaling isn't stable:\nfirst:\n%s\nsecond:\n%s", data, again)
+			}
+		})
+	}
+}
+
+func TestMarshalKeepsTemplates(t *testing.T) {
+	bp, err := blueprint.Parse([]byte(`{
+		"T": {"@params": ["n", "r"], "rooms": {"@repeat": "$n", "@value": "$r"}},
+		"a": {"@template": "T", "n": 2, "r": {"@rule": "Room"}},
+		"b": {"@repeat": 2, "@value": ["B"]}
+	}`))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	expect := `{
+    "T": {
+        "@params": ["n", "r"],
+        "rooms": {
+            "@repeat": "$n",
+            "@value": "$r"
+        }
+    },
+    "a": {
+        "@template": "T",
+        "n": 2,
+        "r": {
+            "@rule": "Room"
+        }
+    },
+    "b": {
+        "@repeat": 2,
+        "@value": ["B"]
+    }
+}
+`
+	if actual, err := blueprint.Marshal(bp); err != nil {
+		t.Fatal("unexpected error:", err)
+	} else if string(actual) != expect {
+		t.Errorf("expect:\n%s\nactual:\n%s", expect, actual)
+	}
+}
+
+// TestMarshalRooms checks that the room library is written as it is defined and that it is read back identically in
+// every format.
+func TestMarshalRooms(t *testing.T) {
+	bp, err := blueprint.ParseFile(filepath.Join("..", "..", "rooms.json"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	actual, err := blueprint.Marshal(bp)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	golden := filepath.Join("testdata", "rooms.golden")
+	if *update {
+		if err := os.WriteFile(golden, actual, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expect, err := os.ReadFile(golden); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(expect, actual) {
+		t.Errorf("output differs from %v:\nexpect:\n%s\nactual:\n%s", golden, expect, actual)
+	}
+
+	for _, format := range []blueprint.Format{blueprint.JSON, blueprint.YAML, blueprint.TOML} {
+		t.Run(format.String(), func(t *testing.T) {
+			data, err := blueprint.Encode(bp, format)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			again, err := blueprint.ParseAs(data, format)
+			if err != nil {
+				t.Fatalf("cannot parse encoded blueprint: %v\n%s", err, data)
+			}
+			compare(t, bp, again)
+			if data, err := blueprint.Marshal(again); err != nil {
+				t.Fatal("unexpected error:", err)
+			} else if !bytes.Equal(actual, data) {
+				t.Errorf("round trip changed the blueprint:\nexpect:\n%s\nactual:\n%s", actual, data)
+			}
+		})
+	}
+}
+
+func TestMarshalIsCanonical(t *testing.T) {
+	a, err := blueprint.Parse([]byte(`{"b":[1,2],"a":{"y":"1","x":"2"}}`))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	b, err := blueprint.Parse([]byte("{\n\t\"a\": {\"x\": \"2\", \"y\": \"1\"},\n\t\"b\": [1, 2]\n}"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	da, _ := blueprint.Marshal(a)
+	db, _ := blueprint.Marshal(b)
+	if !bytes.Equal(da, db) {
+		t.Errorf("blueprints that only differ in formatting are written differently:\n%s\n%s", da, db)
+	}
+}
@@ -0,0 +1,52 @@
+package blueprint
+
+import "fmt"
+
+// A Position describes where something is defined in a blueprint.
+type Position struct {
+	// File is the name of the file. It is empty if the blueprint wasn't parsed from a file.
+	File string
+	// Line is the line in the file, starting at 1. It is 0 if unknown.
+	Line int
+	// Path describes how the position is reached. For properties, it is the list of keys and indices leading to it,
+	// separated by '/'.
+	Path string
+}
+
+// String returns the position in the form "<file>:<line> at <path>". Unknown parts are left out.
+func (p Position) String() string {
+	s := p.File
+	if p.Line > 0 {
+		if s == "" {
+			s = fmt.Sprintf("line %v", p.Line)
+		} else {
+			s = fmt.Sprintf("%v:%v", s, p.Line)
+		}
+	}
+	if p.Path != "" {
+		if s == "" {
+			s = p.Path
+		} else {
+			s = fmt.Sprintf("%v at %v", s, p.Path)
+		}
+	}
+	return s
+}
+
+// A PositionError is an error that occurred at a certain position in a blueprint.
+type PositionError struct {
+	Position Position
+	Err      error
+}
+
+func (e *PositionError) Error() string {
+	if pos := e.Position.String(); pos == "" {
+		return e.Err.Error()
+	} else {
+		return fmt.Sprintf("%v: %v", pos, e.Err)
+	}
+}
+
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,84 @@
+package blueprint
+
+import "sort"
+
+// A Binding describes a property as it is visible from a blueprint.
+type Binding struct {
+	Property string
+	// Origin is the blueprint that defines the property as Values() or Child() resolve it and Position is where it is
+	// defined. For children, it is the location of the child.
+	Origin   *Blueprint
+	Position Position
+	// Shadowed lists the positions of the definitions of the same property that are hidden by Origin, in the order in
+	// which they would be searched.
+	Shadowed []Position
+}
+
+// ResolvedProperties returns all properties with values that are visible from the Blueprint, including the ones that
+// are defined in included blueprints and parents. They are sorted by name.
+func (b *Blueprint) ResolvedProperties() []Binding {
+	return b.resolve(func(scope *Blueprint) map[string]Position {
+		positions := make(map[string]Position, len(scope.values))
+		for property := range scope.values {
+			positions[property] = scope.positions[property]
+		}
+		return positions
+	})
+}
+
+// ResolvedChildren returns all children that are visible from the Blueprint, including the ones that are defined in
+// included blueprints and parents. They are sorted by name.
+func (b *Blueprint) ResolvedChildren() []Binding {
+	return b.resolve(func(scope *Blueprint) map[string]Position {
+		positions := make(map[string]Position, len(scope.children))
+		for property, child := range scope.children {
+			positions[property] = child.location
+		}
+		return positions
+	})
+}
+
+// resolve collects the properties that defined returns for each scope. The first scope in lookup order that defines
+// a property is its origin.
+func (b *Blueprint) resolve(defined func(scope *Blueprint) map[string]Position) []Binding {
+	bindings := map[string]*Binding{}
+	for _, scope := range b.lookupOrder() {
+		for property, pos := range defined(scope) {
+			if binding, ok := bindings[property]; ok {
+				binding.Shadowed = append(binding.Shadowed, pos)
+			} else {
+				bindings[property] = &Binding{Property: property, Origin: scope, Position: pos}
+			}
+		}
+	}
+
+	out := make([]Binding, 0, len(bindings))
+	for _, binding := range bindings {
+		out = append(out, *binding)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Property < out[j].Property })
+	return out
+}
+
+// lookupOrder returns the blueprints in the order in which scope searches them. Blueprints that are included several
+// times are only listed once.
+func (b *Blueprint) lookupOrder() []*Blueprint {
+	seen := map[*Blueprint]bool{}
+	var order []*Blueprint
+	var add func(bp *Blueprint)
+	add = func(bp *Blueprint) {
+		if seen[bp] {
+			return
+		}
+		seen[bp] = true
+		order = append(order, bp)
+		for _, included := range bp.includes {
+			add(included)
+		}
+	}
+
+	for bp := b; bp != nil; bp = bp.parent {
+		add(bp)
+	}
+	return order
+}
@@ -0,0 +1,343 @@
+package blueprint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Properties that define and instantiate templates.
+//
+// A template is a blueprint with the property "@params", which lists the names of its parameters. It is instantiated
+// by a blueprint that names it in "@template" and passes the arguments as properties of the same names, e.g.
+//
+//	"Beds": {"@params": ["n", "texture"], "texture": 1, "@rule": "RoomLine", "rooms": {"@repeat": "$n", "@value": ...}}
+//	"Dorm": {"@template": "Beds", "n": 4}
+//
+// In the instance, every value "$<param>" in the template and its children is replaced by the values of the argument.
+// Properties that the template defines under the name of a parameter serve as default arguments. The instance keeps
+// the scope of the instantiating blueprint, so all other names are resolved where the template is used.
+//
+// A child with the property "@repeat" is replaced by the values in "@value", which are repeated as many times as
+// "@repeat" says.
+const (
+	Params      = "@params"
+	Template    = "@template"
+	Repeat      = "@repeat"
+	RepeatValue = "@value"
+)
+
+// An expander instantiates the templates in a blueprint.
+type expander struct {
+	// stack contains the templates that are being instantiated and names their names
+	stack []*Blueprint
+	names []string
+}
+
+// expand instantiates templates in a blueprint and its children and unrolls repetitions. Template definitions are
+// only expanded when they are instantiated.
+func (e *expander) expand(b *Blueprint) error {
+	if _, ok := b.typed[Params]; ok {
+		return nil
+	}
+
+	source := b.copy()
+	_, site := b.typed[Template]
+	if site {
+		// the arguments are expanded in the scope of the instantiating blueprint
+		if err := e.children(b); err != nil {
+			return err
+		}
+		name, def, err := e.instantiate(b)
+		if err != nil {
+			return err
+		}
+
+		e.stack, e.names = append(e.stack, def), append(e.names, name)
+		err = e.children(b)
+		e.stack, e.names = e.stack[:len(e.stack)-1], e.names[:len(e.names)-1]
+		if err != nil {
+			return err
+		}
+	} else if err := e.children(b); err != nil {
+		return err
+	}
+
+	if changed, err := unroll(b); err != nil {
+		return err
+	} else if site || changed {
+		b.source = source
+	}
+	return nil
+}
+
+// copy returns a copy of a blueprint that isn't affected when the properties and children of the original change.
+func (b *Blueprint) copy() *Blueprint {
+	c := *b
+	c.keys = append([]string{}, b.keys...)
+	c.values = make(map[string][]string, len(b.values))
+	c.typed = make(map[string][]Value, len(b.typed))
+	c.weights = make(map[string][]float64, len(b.weights))
+	c.positions = make(map[string]Position, len(b.positions))
+	for k := range b.typed {
+		c.values[k], c.typed[k], c.weights[k], c.positions[k] = b.values[k], b.typed[k], b.weights[k], b.positions[k]
+	}
+	c.children = make(map[string]*Blueprint, len(b.children))
+	for name, child := range b.children {
+		c.children[name] = child
+	}
+	return &c
+}
+
+func (e *expander) children(b *Blueprint) error {
+	names := b.Children()
+	sort.Strings(names)
+	for _, name := range names {
+		if err := e.expand(b.children[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// instantiate replaces the content of a blueprint with the template it names.
+func (e *expander) instantiate(site *Blueprint) (string, *Blueprint, error) {
+	fail := func(format string, a ...interface{}) error {
+		return &PositionError{site.positions[Template],
+			fmt.Errorf("%w: %v", ErrInvalidScript, fmt.Sprintf(format, a...))}
+	}
+
+	values := site.typed[Template]
+	if len(values) != 1 {
+		return "", nil, fail("'%v' must name exactly one template", Template)
+	}
+	name, err := values[0].Text()
+	if err != nil {
+		return "", nil, fail("'%v' must name exactly one template", Template)
+	}
+
+	scope := site.parent
+	if scope == nil {
+		scope = site
+	}
+	var def *Blueprint
+	if refs := scope.Values(name); len(refs) == 1 {
+		def = scope.Child(refs[0])
+	}
+	if def == nil {
+		return "", nil, fail("template '%v' is not defined", name)
+	} else if _, ok := def.typed[Params]; !ok {
+		return "", nil, fail("'%v' is not a template", name)
+	}
+	for i, open := range e.stack {
+		if open == def {
+			return "", nil, fail("template cycle %v", strings.Join(append(e.names[i:], name), " -> "))
+		}
+	}
+
+	inst := &instance{name: name, def: def, params: map[string]bool{}, args: map[string]*Blueprint{}}
+	for _, v := range def.typed[Params] {
+		if param, err := v.Text(); err != nil {
+			return "", nil, &PositionError{def.positions[Params],
+				fmt.Errorf("%w: parameters must be names but got %v", ErrInvalidScript, v)}
+		} else if !inst.params[param] {
+			inst.params[param] = true
+			inst.order = append(inst.order, param)
+		}
+	}
+
+	args := *site
+	for _, k := range args.keys {
+		if k == Template {
+			continue
+		} else if !inst.params[k] {
+			return "", nil, &PositionError{args.positions[k],
+				fmt.Errorf("%w: template '%v' has no parameter '%v'", ErrInvalidScript, name, k)}
+		}
+		inst.args[k] = &args
+	}
+	for _, param := range inst.order {
+		if _, ok := inst.args[param]; ok {
+			continue
+		} else if _, ok := def.typed[param]; ok {
+			inst.args[param] = def
+		} else {
+			return "", nil, fail("template '%v' is missing argument '%v'", name, param)
+		}
+	}
+
+	// the content of site is replaced, the arguments are kept in args
+	*site = *newBlueprint(site.parent, site.location)
+	for name, child := range args.children {
+		child.parent = site.parent
+		site.children[argChild(name)] = child
+	}
+	return name, def, inst.fill(site, def)
+}
+
+// An instance is a template with its arguments.
+type instance struct {
+	name   string
+	def    *Blueprint
+	params map[string]bool
+	// args maps parameters to the blueprints that define their values, order lists the parameters as they are declared
+	args  map[string]*Blueprint
+	order []string
+}
+
+// fill copies the properties of src to dst, replaces the parameters and copies the children. The parameters become
+// properties of the instance as well.
+func (in *instance) fill(dst, src *Blueprint) error {
+	keys := src.keys
+	if src == in.def {
+		keys = nil
+		for _, k := range src.keys {
+			if k != Params && !in.params[k] {
+				keys = append(keys, k)
+			}
+		}
+		for _, k := range in.order {
+			if in.args[k] == in.def {
+				if err := in.copyChildren(dst, k); err != nil {
+					return err
+				}
+			}
+			typed, weights := in.argument(k, 1)
+			set(dst, k, typed, weights, in.args[k].positions[k])
+		}
+	}
+
+	for _, k := range keys {
+		var typed []Value
+		var weights []float64
+		for i, v := range src.typed[k] {
+			weight := src.weights[k][i]
+			str, _ := v.data.(string)
+			if child, ok := src.children[str]; ok {
+				c := newBlueprint(dst, child.location)
+				if err := in.fill(c, child); err != nil {
+					return err
+				}
+				dst.children[str] = c
+				typed, weights = append(typed, v), append(weights, weight)
+			} else if !strings.HasPrefix(str, "$") {
+				typed, weights = append(typed, v), append(weights, weight)
+			} else if param := str[1:]; !in.params[param] {
+				return &PositionError{src.positions[k],
+					fmt.Errorf("%w: '%v' is not a parameter of template '%v'", ErrInvalidScript, param, in.name)}
+			} else {
+				ts, ws := in.argument(param, weight)
+				typed, weights = append(typed, ts...), append(weights, ws...)
+			}
+		}
+		set(dst, k, typed, weights, src.positions[k])
+	}
+	return nil
+}
+
+// copyChildren copies the children that the template uses as default for a parameter.
+func (in *instance) copyChildren(dst *Blueprint, param string) error {
+	for _, v := range in.def.typed[param] {
+		name, _ := v.data.(string)
+		if child, ok := in.def.children[name]; ok {
+			c := newBlueprint(dst, child.location)
+			if err := in.fill(c, child); err != nil {
+				return err
+			}
+			dst.children[name] = c
+		}
+	}
+	return nil
+}
+
+// argument returns the values of an argument. Children that are passed as arguments are renamed, see argChild.
+func (in *instance) argument(param string, weight float64) ([]Value, []float64) {
+	args := in.args[param]
+	typed := make([]Value, len(args.typed[param]))
+	weights := make([]float64, len(typed))
+	for i, v := range args.typed[param] {
+		if name, ok := v.data.(string); ok && args != in.def {
+			if _, ok := args.children[name]; ok {
+				v = Value{argChild(name)}
+			}
+		}
+		typed[i], weights[i] = v, weight*args.weights[param][i]
+	}
+	return typed, weights
+}
+
+func set(b *Blueprint, k string, typed []Value, weights []float64, pos Position) {
+	if _, ok := b.typed[k]; !ok {
+		b.keys = append(b.keys, k)
+	}
+	b.typed[k] = typed
+	b.values[k] = make([]string, len(typed))
+	for i, v := range typed {
+		b.values[k][i] = v.String()
+	}
+	b.weights[k] = weights
+	b.positions[k] = pos
+}
+
+// argChild renames a child that is passed as an argument so that it doesn't collide with the children of the
+// template.
+func argChild(name string) string {
+	return "*$" + strings.TrimPrefix(name, "*")
+}
+
+// unroll replaces children that repeat values by the repeated values. It returns whether there were any.
+func unroll(b *Blueprint) (bool, error) {
+	unrolled := false
+	for _, k := range b.keys {
+		var typed []Value
+		var weights []float64
+		changed := false
+		for i, v := range b.typed[k] {
+			str, _ := v.data.(string)
+			child, ok := b.children[str]
+			if ok {
+				_, ok = child.typed[Repeat]
+			}
+			if !ok {
+				typed, weights = append(typed, v), append(weights, b.weights[k][i])
+				continue
+			}
+
+			n, err := child.Int(Repeat)
+			if err != nil {
+				return false, err
+			} else if n < 0 {
+				return false, &PositionError{child.positions[Repeat],
+					fmt.Errorf("%w: '%v' must not be negative", ErrInvalidScript, Repeat)}
+			}
+			values, ok := child.typed[RepeatValue]
+			if !ok {
+				return false, &PositionError{child.location,
+					fmt.Errorf("%w: '%v' is missing", ErrInvalidScript, RepeatValue)}
+			}
+
+			// children of the repetition become children of b
+			for j := 0; j < n; j++ {
+				for l, value := range values {
+					if name, ok := value.data.(string); ok {
+						if grandchild, ok := child.children[name]; ok {
+							value = Value{str + "." + strings.TrimPrefix(name, "*")}
+							grandchild.parent = b
+							b.children[value.data.(string)] = grandchild
+						}
+					}
+					typed = append(typed, value)
+					weights = append(weights, b.weights[k][i]*child.weights[RepeatValue][l])
+				}
+			}
+			delete(b.children, str)
+			changed = true
+		}
+
+		if changed {
+			set(b, k, typed, weights, b.positions[k])
+			unrolled = true
+		}
+	}
+	return unrolled, nil
+}
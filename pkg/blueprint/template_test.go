@@ -0,0 +1,187 @@
+package blueprint_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/nilsbu/arch/pkg/blueprint"
+)
+
+func TestTemplates(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		json   string
+		checks []ksvs
+	}{
+		{
+			"arguments and defaults",
+			`{
+				"Beds": {"@params": ["n", "texture"], "texture": 1, "@rule": "Occupy", "count": "$n", "t": "$texture"},
+				"a": {"@template": "Beds", "n": 3},
+				"b": {"@template": "Beds", "n": 2, "texture": [4, 5]}
+			}`,
+			[]ksvs{
+				{[]string{"*a0", "@rule"}, []string{"Occupy"}},
+				{[]string{"*a0", "count"}, []string{"3"}},
+				{[]string{"*a0", "n"}, []string{"3"}},
+				{[]string{"*a0", "t"}, []string{"1"}},
+				{[]string{"*a0", "texture"}, []string{"1"}},
+				{[]string{"*a0", blueprint.Template}, nil},
+				{[]string{"*a0", blueprint.Params}, nil},
+				{[]string{"*b0", "count"}, []string{"2"}},
+				{[]string{"*b0", "t"}, []string{"4", "5"}},
+			},
+		},
+		{
+			"repeat",
+			`{
+				"Line": {"@params": ["n", "room"], "@rule": "RoomLine", "rooms": {"@repeat": "$n", "@value": "$room"}},
+				"a": {"@template": "Line", "n": 3, "room": "Room"},
+				"b": {"@template": "Line", "n": 0, "room": "Room"},
+				"c": {"rooms": {"@repeat": 2, "@value": ["A", "B"]}, "x": ["Y", {"@repeat": 1, "@value": "Z"}]}
+			}`,
+			[]ksvs{
+				{[]string{"*a0", "rooms"}, []string{"Room", "Room", "Room"}},
+				{[]string{"*b0", "rooms"}, []string{}},
+				{[]string{"*c0", "rooms"}, []string{"A", "B", "A", "B"}},
+				{[]string{"*c0", "x"}, []string{"Y", "Z"}},
+			},
+		},
+		{
+			"children in templates and arguments",
+			`{
+				"x": "root",
+				"Box": {"@params": ["content"], "x": "box", "inner": {"@rule": "Frame", "content": "$content"}},
+				"a": {"@template": "Box", "content": {"@rule": "Room"}}
+			}`,
+			[]ksvs{
+				{[]string{"*a0", "inner"}, []string{"*inner0"}},
+				{[]string{"*a0", "*inner0", "@rule"}, []string{"Frame"}},
+				{[]string{"*a0", "*inner0", "content"}, []string{"*$content0"}},
+				{[]string{"*a0", "*inner0", "*$content0", "@rule"}, []string{"Room"}},
+				// arguments keep the scope in which they are written
+				{[]string{"*a0", "*inner0", "*$content0", "x"}, []string{"root"}},
+				{[]string{"*a0", "*inner0", "x"}, []string{"box"}},
+			},
+		},
+		{
+			"default child",
+			`{
+				"Box": {"@params": ["content"], "content": {"@rule": "NOP"}, "c": "$content"},
+				"a": {"@template": "Box"}
+			}`,
+			[]ksvs{
+				{[]string{"*a0", "c"}, []string{"*content0"}},
+				{[]string{"*a0", "*content0", "@rule"}, []string{"NOP"}},
+			},
+		},
+		{
+			"nested templates",
+			`{
+				"Inner": {"@params": ["v"], "value": "$v"},
+				"Outer": {"@params": ["w"], "in": {"@template": "Inner", "v": "$w"}},
+				"a": {"@template": "Outer", "w": "deep"},
+				"b": {"@template": "Inner", "v": {"@template": "Inner", "v": "arg"}}
+			}`,
+			[]ksvs{
+				{[]string{"*a0", "*in0", "value"}, []string{"deep"}},
+				{[]string{"*b0", "*$v0", "value"}, []string{"arg"}},
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bp, err := blueprint.Parse([]byte(c.json))
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			for _, check := range c.checks {
+				b := bp
+				for _, child := range check.properties[:len(check.properties)-1] {
+					if b = b.Child(child); b == nil {
+						t.Fatalf("child '%v' not found", child)
+					}
+				}
+				property := check.properties[len(check.properties)-1]
+				if values := b.Values(property); !reflect.DeepEqual(check.values, values) {
+					t.Errorf("wrong values for %v:\nexpect: %v\nactual: %v", check.properties, check.values, values)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateWeights(t *testing.T) {
+	bp, err := blueprint.Parse([]byte(`{
+		"T": {"@params": ["r"], "rooms": ["$r*2", "B"]},
+		"a": {"@template": "T", "r": ["A*3", "C"]}
+	}`))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	a := bp.Child("*a0")
+	if values := a.Values("rooms"); !reflect.DeepEqual([]string{"A", "C", "B"}, values) {
+		t.Errorf("wrong values: %v", values)
+	}
+	if weights := a.Weights("rooms"); !reflect.DeepEqual([]float64{6, 2, 1}, weights) {
+		t.Errorf("wrong weights: %v", weights)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		json string
+		line int
+	}{
+		{"undefined", "{\n\"a\": {\"@template\": \"T\"}}", 2},
+		{"not a template", "{\"T\": {\"x\": 1},\n\"a\": {\"@template\": \"T\"}}", 2},
+		{"two templates", "{\"T\": {\"@params\": []},\n\"a\": {\"@template\": [\"T\", \"T\"]}}", 2},
+		{"unknown parameter", "{\"T\": {\"@params\": []},\n\"a\": {\"@template\": \"T\",\n\"x\": 1}}", 3},
+		{"missing argument", "{\"T\": {\"@params\": [\"x\"]},\n\"a\": {\"@template\": \"T\"}}", 2},
+		{"not a parameter", "{\"T\": {\"@params\": [],\n\"v\": \"$x\"},\n\"a\": {\"@template\": \"T\"}}", 2},
+		{"cycle", "{\"T\": {\"@params\": [],\n\"c\": {\"@template\": \"T\"}},\n\"a\": {\"@template\": \"T\"}}", 2},
+		{"mutual cycle", `{
+			"T": {"@params": [], "c": {"@template": "U"}},
+			"U": {"@params": [], "c": {"@template": "T"}},
+			"a": {"@template": "T"}}`, 3},
+		{"negative repeat", "{\"a\": {\n\"@repeat\": -1, \"@value\": \"x\"}}", 2},
+		{"repeat without number", "{\"a\": {\n\"@repeat\": \"x\", \"@value\": \"x\"}}", 2},
+		{"repeat without value", "{\"a\":\n{\"@repeat\": 1}}", 2},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := blueprint.Parse([]byte(c.json))
+			var pe *blueprint.PositionError
+			var te *blueprint.TypeError
+			if err == nil {
+				t.Fatal("expected error but none occurred")
+			} else if errors.As(err, &te) {
+				if te.Position.Line != c.line {
+					t.Errorf("expected error on line %v but got %v", c.line, err)
+				}
+			} else if !errors.Is(err, blueprint.ErrInvalidScript) {
+				t.Errorf("expected ErrInvalidScript but got %v", err)
+			} else if !errors.As(err, &pe) {
+				t.Errorf("expected *PositionError but got %v", err)
+			} else if pe.Position.Line != c.line {
+				t.Errorf("expected error on line %v but got %v", c.line, err)
+			}
+		})
+	}
+}
+
+func TestTemplatesFromIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.json": `{"@include": "lib.json", "a": {"@template": "Line", "n": 2}}`,
+		"lib.json":  `{"Line": {"@params": ["n"], "rooms": {"@repeat": "$n", "@value": "Room"}}, "Room": {"x": 1}}`,
+	})
+
+	bp, err := blueprint.ParseFile(filepath.Join(dir, "main.json"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if values := bp.Child("*a0").Values("rooms"); !reflect.DeepEqual([]string{"Room", "Room"}, values) {
+		t.Errorf("wrong values: %v", values)
+	}
+}
@@ -0,0 +1,214 @@
+package blueprint
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// tomlDecoder turns the expressions of a TOML document into an object. Tables and arrays of tables become objects
+// and lists of objects, just like inline tables do.
+//
+// The stable toml.Decoder only reports positions for syntax errors and loses the order of keys, but blueprints need
+// the line of every key to report problems and keep the order of keys when they are encoded. That's why the parser of
+// go-toml/v2/unstable is used. It isn't covered by semantic versioning, so go.mod pins the exact version.
+type tomlDecoder struct {
+	p unstable.Parser
+}
+
+func decodeTOML(data []byte) (*object, error) {
+	d := &tomlDecoder{}
+	d.p.Reset(data)
+
+	root := &object{line: 1, members: map[string]*member{}}
+	current := root
+	for d.p.NextExpression() {
+		expr := d.p.Expression()
+		var err error
+		switch expr.Kind {
+		case unstable.KeyValue:
+			err = d.keyValue(current, expr)
+		case unstable.Table:
+			current, err = d.table(root, expr)
+		case unstable.ArrayTable:
+			current, err = d.arrayTable(root, expr)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := d.p.Error(); err != nil {
+		var pe *unstable.ParserError
+		pos := Position{}
+		if errors.As(err, &pe) && len(pe.Highlight) > 0 {
+			pos.Line = d.p.Shape(d.p.Range(pe.Highlight)).Start.Line
+		}
+		return nil, &PositionError{pos, fmt.Errorf("cannot parse TOML: %w", err)}
+	}
+	return root, nil
+}
+
+// line returns the line on which a node starts or 0 if it has no position.
+func (d *tomlDecoder) line(n *unstable.Node) int {
+	if n.Raw.Length == 0 {
+		return 0
+	}
+	return d.p.Shape(n.Raw).Start.Line
+}
+
+// keys returns the parts of a dotted key and the line of the first one.
+func (d *tomlDecoder) keys(n *unstable.Node) ([]string, int) {
+	var keys []string
+	line := 0
+	it := n.Key()
+	for it.Next() {
+		if line == 0 {
+			line = d.line(it.Node())
+		}
+		keys = append(keys, string(it.Node().Data))
+	}
+	return keys, line
+}
+
+// descend returns the object that is stored under key in obj. It is created if it doesn't exist. If there is a list
+// of objects, the last one is returned, as is required for arrays of tables.
+func (d *tomlDecoder) descend(obj *object, key string, line int) (*object, error) {
+	m, ok := obj.members[key]
+	if !ok {
+		child := &object{line: line, members: map[string]*member{}}
+		obj.keys = append(obj.keys, key)
+		obj.members[key] = &member{line: line, value: child}
+		return child, nil
+	}
+
+	switch value := m.value.(type) {
+	case *object:
+		return value, nil
+	case []*member:
+		if len(value) > 0 {
+			if child, ok := value[len(value)-1].value.(*object); ok {
+				return child, nil
+			}
+		}
+	}
+	return nil, &PositionError{Position{Line: line},
+		fmt.Errorf("%w: '%v' is already defined as a value", ErrInvalidScript, key)}
+}
+
+func (d *tomlDecoder) table(root *object, expr *unstable.Node) (*object, error) {
+	keys, line := d.keys(expr)
+	obj := root
+	for _, key := range keys {
+		var err error
+		if obj, err = d.descend(obj, key, line); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+func (d *tomlDecoder) arrayTable(root *object, expr *unstable.Node) (*object, error) {
+	keys, line := d.keys(expr)
+	obj := root
+	for _, key := range keys[:len(keys)-1] {
+		var err error
+		if obj, err = d.descend(obj, key, line); err != nil {
+			return nil, err
+		}
+	}
+
+	key := keys[len(keys)-1]
+	child := &object{line: line, members: map[string]*member{}}
+	if m, ok := obj.members[key]; !ok {
+		obj.keys = append(obj.keys, key)
+		obj.members[key] = &member{line: line, value: []*member{{line: line, value: child}}}
+	} else if elems, ok := m.value.([]*member); ok {
+		m.value = append(elems, &member{line: line, value: child})
+	} else {
+		return nil, &PositionError{Position{Line: line},
+			fmt.Errorf("%w: '%v' is already defined as a value", ErrInvalidScript, key)}
+	}
+	return child, nil
+}
+
+func (d *tomlDecoder) keyValue(obj *object, expr *unstable.Node) error {
+	keys, line := d.keys(expr)
+	for _, key := range keys[:len(keys)-1] {
+		var err error
+		if obj, err = d.descend(obj, key, line); err != nil {
+			return err
+		}
+	}
+
+	m, err := d.value(expr.Value(), line)
+	if err != nil {
+		return err
+	}
+	m.line = line
+
+	key := keys[len(keys)-1]
+	if _, ok := obj.members[key]; !ok {
+		obj.keys = append(obj.keys, key)
+	}
+	obj.members[key] = m
+	return nil
+}
+
+// value converts a value node into a member. Nodes without a position are given the line of their parent.
+func (d *tomlDecoder) value(n *unstable.Node, parent int) (*member, error) {
+	m := &member{line: d.line(n)}
+	if m.line == 0 {
+		m.line = parent
+	}
+
+	switch n.Kind {
+	case unstable.String:
+		m.value = string(n.Data)
+	case unstable.Bool:
+		m.value = string(n.Data) == "true"
+	case unstable.Integer:
+		if i, err := strconv.ParseInt(strings.ReplaceAll(string(n.Data), "_", ""), 0, 64); err != nil {
+			return nil, &PositionError{Position{Line: m.line}, fmt.Errorf("cannot parse TOML: %w", err)}
+		} else {
+			m.value = float64(i)
+		}
+	case unstable.Float:
+		str := strings.ReplaceAll(string(n.Data), "_", "")
+		if strings.TrimLeft(str, "+-") == "nan" {
+			str = "nan"
+		}
+		if f, err := strconv.ParseFloat(str, 64); err != nil {
+			return nil, &PositionError{Position{Line: m.line}, fmt.Errorf("cannot parse TOML: %w", err)}
+		} else {
+			m.value = f
+		}
+	case unstable.Array:
+		elems := []*member{}
+		it := n.Children()
+		for it.Next() {
+			if elem, err := d.value(it.Node(), m.line); err != nil {
+				return nil, err
+			} else {
+				elems = append(elems, elem)
+			}
+		}
+		m.value = elems
+	case unstable.InlineTable:
+		obj := &object{line: m.line, members: map[string]*member{}}
+		it := n.Children()
+		for it.Next() {
+			if err := d.keyValue(obj, it.Node()); err != nil {
+				return nil, err
+			}
+		}
+		m.value = obj
+	default:
+		// dates and times aren't supported and are rejected like null
+		m.value = nil
+	}
+	return m, nil
+}
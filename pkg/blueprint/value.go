@@ -0,0 +1,222 @@
+package blueprint
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrWrongType is returned when a property doesn't hold a value of the requested type.
+var ErrWrongType = errors.New("value has wrong type")
+
+// A TypeError is returned when a value cannot be converted to the requested type. It matches ErrWrongType.
+type TypeError struct {
+	// Property and Position are only set when the value was requested through a Blueprint.
+	Property string
+	Position Position
+	Expected string
+	// Actual describes the value that was found.
+	Actual string
+}
+
+func (e *TypeError) Error() string {
+	msg := fmt.Sprintf("%v: expected %v but got %v", ErrWrongType, e.Expected, e.Actual)
+	if e.Property != "" {
+		msg = fmt.Sprintf("property '%v': %v", e.Property, msg)
+	}
+	if pos := e.Position.String(); pos != "" {
+		msg = fmt.Sprintf("%v: %v", pos, msg)
+	}
+	return msg
+}
+
+func (e *TypeError) Is(target error) bool {
+	return target == ErrWrongType
+}
+
+// A Value is a single value of a property. It is either a string, a number, a boolean, a tuple or a distribution.
+// Tuples are written as arrays inside the list of values of a property that only contain numbers and booleans, e.g.
+// "sizes": [[2, 4], [3, 2]].
+type Value struct {
+	// data is string, float64, bool, []Value or Distribution
+	data interface{}
+}
+
+// String returns the value as it is returned by Blueprint.Values(). Tuples are written as JSON arrays.
+func (v Value) String() string {
+	switch data := v.data.(type) {
+	case string:
+		return data
+	case float64:
+		return strconv.FormatFloat(data, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(data)
+	case []Value:
+		strs := make([]string, len(data))
+		for i, elem := range data {
+			strs[i] = elem.String()
+		}
+		return "[" + strings.Join(strs, ",") + "]"
+	case Distribution:
+		return data.String()
+	default:
+		return fmt.Sprint(data)
+	}
+}
+
+// Text returns the value if it is a string.
+func (v Value) Text() (string, error) {
+	if str, ok := v.data.(string); ok {
+		return str, nil
+	} else {
+		return "", v.mismatch("string")
+	}
+}
+
+// Float returns the value if it is a number.
+func (v Value) Float() (float64, error) {
+	if f, ok := v.data.(float64); ok {
+		return f, nil
+	} else {
+		return 0, v.mismatch("number")
+	}
+}
+
+// Int returns the value if it is a whole number that fits into an int.
+func (v Value) Int() (int, error) {
+	if f, ok := v.data.(float64); ok && f == math.Trunc(f) && f >= math.MinInt && f <= math.MaxInt {
+		return int(f), nil
+	} else {
+		return 0, v.mismatch("integer")
+	}
+}
+
+// Bool returns the value if it is a boolean.
+func (v Value) Bool() (bool, error) {
+	if b, ok := v.data.(bool); ok {
+		return b, nil
+	} else {
+		return false, v.mismatch("boolean")
+	}
+}
+
+// IntSlice returns the value if it is a tuple of integers.
+func (v Value) IntSlice() ([]int, error) {
+	tuple, ok := v.data.([]Value)
+	if !ok {
+		return nil, v.mismatch("tuple of integers")
+	}
+	ints := make([]int, len(tuple))
+	for i, elem := range tuple {
+		var err error
+		if ints[i], err = elem.Int(); err != nil {
+			return nil, v.mismatch("tuple of integers")
+		}
+	}
+	return ints, nil
+}
+
+func (v Value) mismatch(expected string) *TypeError {
+	actual := v.String()
+	if _, ok := v.data.(string); ok {
+		actual = strconv.Quote(actual)
+	}
+	return &TypeError{Expected: expected, Actual: actual}
+}
+
+// TypedValues returns the values associated with a property with their types.
+// If the queried Blueprint doesn't contain that property, the included blueprints and the parents are called
+// recursively.
+func (b *Blueprint) TypedValues(property string) []Value {
+	if scope := b.scope(property); scope != nil {
+		return scope.typed[property]
+	} else {
+		return nil
+	}
+}
+
+// Float returns the value of a property that has exactly one value, which must be a number.
+func (b *Blueprint) Float(property string) (float64, error) {
+	if v, err := b.single(property, "number"); err != nil {
+		return 0, err
+	} else {
+		f, err := v.Float()
+		return f, b.locate(property, err)
+	}
+}
+
+// Int returns the value of a property that has exactly one value, which must be an integer.
+func (b *Blueprint) Int(property string) (int, error) {
+	if v, err := b.single(property, "integer"); err != nil {
+		return 0, err
+	} else {
+		i, err := v.Int()
+		return i, b.locate(property, err)
+	}
+}
+
+// Bool returns the value of a property that has exactly one value, which must be a boolean.
+func (b *Blueprint) Bool(property string) (bool, error) {
+	if v, err := b.single(property, "boolean"); err != nil {
+		return false, err
+	} else {
+		x, err := v.Bool()
+		return x, b.locate(property, err)
+	}
+}
+
+// IntSlice returns the values of a property as integers. The property may either be a list of integers, e.g.
+// "rect": [0, 0, 80, 40], or a single tuple of integers.
+func (b *Blueprint) IntSlice(property string) ([]int, error) {
+	values := b.TypedValues(property)
+	if values == nil {
+		return nil, b.locate(property, &TypeError{Expected: "list of integers", Actual: "nothing"})
+	} else if len(values) == 1 {
+		if ints, err := values[0].IntSlice(); err == nil {
+			return ints, nil
+		}
+	}
+
+	ints := make([]int, len(values))
+	for i, v := range values {
+		var err error
+		if ints[i], err = v.Int(); err != nil {
+			return nil, b.locate(property, &TypeError{Expected: "list of integers", Actual: describe(values)})
+		}
+	}
+	return ints, nil
+}
+
+// single returns the only value of a property.
+func (b *Blueprint) single(property, expected string) (Value, error) {
+	if values := b.TypedValues(property); len(values) != 1 {
+		return Value{}, b.locate(property, &TypeError{Expected: "one " + expected, Actual: describe(values)})
+	} else {
+		return values[0], nil
+	}
+}
+
+// locate adds the property and its position to a *TypeError.
+func (b *Blueprint) locate(property string, err error) error {
+	var te *TypeError
+	if errors.As(err, &te) {
+		te.Property = property
+		te.Position, _ = b.Position(property)
+	}
+	return err
+}
+
+func describe(values []Value) string {
+	if len(values) == 0 {
+		return "nothing"
+	} else if len(values) == 1 {
+		return values[0].mismatch("").Actual
+	}
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = v.String()
+	}
+	return fmt.Sprintf("%v values [%v]", len(values), strings.Join(strs, ", "))
+}
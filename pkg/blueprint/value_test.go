@@ -0,0 +1,100 @@
+package blueprint_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nilsbu/arch/pkg/blueprint"
+)
+
+func TestBlueprintAccessors(t *testing.T) {
+	bp, err := blueprint.Parse([]byte(`{
+		"i": 3, "f": 0.25, "b": true, "s": "3",
+		"list": [0, 0, 80, 40], "tuple": [[2, 4]], "tuples": [[2, 4], [3, 2]],
+		"mixed": [1, "a"], "frac": 2.5
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, c := range []struct {
+		name   string
+		get    func() (interface{}, error)
+		expect interface{}
+		ok     bool
+	}{
+		{"int", func() (interface{}, error) { return bp.Int("i") }, 3, true},
+		{"int from float", func() (interface{}, error) { return bp.Int("frac") }, 0, false},
+		{"int from string", func() (interface{}, error) { return bp.Int("s") }, 0, false},
+		{"missing int", func() (interface{}, error) { return bp.Int("x") }, 0, false},
+		{"int from list", func() (interface{}, error) { return bp.Int("list") }, 0, false},
+		{"float", func() (interface{}, error) { return bp.Float("f") }, .25, true},
+		{"float from int", func() (interface{}, error) { return bp.Float("i") }, 3., true},
+		{"float from bool", func() (interface{}, error) { return bp.Float("b") }, 0., false},
+		{"bool", func() (interface{}, error) { return bp.Bool("b") }, true, true},
+		{"bool from int", func() (interface{}, error) { return bp.Bool("i") }, false, false},
+		{"int slice from list", func() (interface{}, error) { return bp.IntSlice("list") }, []int{0, 0, 80, 40}, true},
+		{"int slice from tuple", func() (interface{}, error) { return bp.IntSlice("tuple") }, []int{2, 4}, true},
+		{"int slice from single int", func() (interface{}, error) { return bp.IntSlice("i") }, []int{3}, true},
+		{"int slice from tuples", func() (interface{}, error) { return bp.IntSlice("tuples") }, []int(nil), false},
+		{"int slice from mixed", func() (interface{}, error) { return bp.IntSlice("mixed") }, []int(nil), false},
+		{"missing int slice", func() (interface{}, error) { return bp.IntSlice("x") }, []int(nil), false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := c.get()
+			if c.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if !c.ok && !errors.Is(err, blueprint.ErrWrongType) {
+				t.Fatalf("expected ErrWrongType but got %v", err)
+			}
+			if !reflect.DeepEqual(c.expect, actual) {
+				t.Errorf("expected %v but got %v", c.expect, actual)
+			}
+		})
+	}
+}
+
+func TestTypedValues(t *testing.T) {
+	bp, err := blueprint.Parse([]byte(`{"sizes": [[2, 4], [3, 2]], "mixed": ["a", 1]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sizes := bp.TypedValues("sizes")
+	if len(sizes) != 2 {
+		t.Fatalf("expected 2 values but got %v", len(sizes))
+	}
+	if size, err := sizes[1].IntSlice(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual([]int{3, 2}, size) {
+		t.Errorf("expected [3 2] but got %v", size)
+	}
+
+	mixed := bp.TypedValues("mixed")
+	if _, err := mixed[0].Int(); !errors.Is(err, blueprint.ErrWrongType) {
+		t.Errorf("expected ErrWrongType but got %v", err)
+	}
+	if i, err := mixed[1].Int(); err != nil || i != 1 {
+		t.Errorf("expected 1 but got %v, %v", i, err)
+	}
+}
+
+func TestTypeErrorPosition(t *testing.T) {
+	bp, _ := blueprint.Parse([]byte("{\n\"a\": {\n\"x\": \"no\"}}"))
+
+	_, err := bp.Child("*a0").Int("x")
+	var te *blueprint.TypeError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected TypeError but got %v", err)
+	}
+	expect := blueprint.TypeError{
+		Property: "x",
+		Position: blueprint.Position{Line: 3, Path: "a/x"},
+		Expected: "integer",
+		Actual:   `"no"`,
+	}
+	if expect != *te {
+		t.Errorf("wrong error:\nexpect: %v\nactual: %v", &expect, te)
+	}
+}
@@ -0,0 +1,88 @@
+package blueprint
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlLine finds the line in the errors of the YAML parser, e.g. "yaml: line 3: did not find expected key".
+var yamlLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// decodeYAML parses YAML data that must contain a mapping. Aliases are resolved.
+func decodeYAML(data []byte) (*object, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		pos := Position{}
+		if match := yamlLine.FindStringSubmatch(err.Error()); match != nil {
+			pos.Line, _ = strconv.Atoi(match[1])
+		}
+		return nil, &PositionError{pos, fmt.Errorf("cannot parse YAML: %w", err)}
+	} else if len(doc.Content) == 0 {
+		return nil, &PositionError{Position{Line: 1}, fmt.Errorf("%w: content must be an object", ErrInvalidScript)}
+	}
+
+	m, err := yamlMember(doc.Content[0])
+	if err != nil {
+		return nil, err
+	} else if obj, ok := m.value.(*object); !ok {
+		return nil, &PositionError{Position{Line: m.line},
+			fmt.Errorf("%w: content must be an object", ErrInvalidScript)}
+	} else {
+		return obj, nil
+	}
+}
+
+func yamlMember(n *yaml.Node) (*member, error) {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+
+	m := &member{line: n.Line}
+	switch n.Kind {
+	case yaml.MappingNode:
+		obj := &object{line: n.Line, members: map[string]*member{}}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i]
+			elem, err := yamlMember(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			elem.line = key.Line
+
+			if _, ok := obj.members[key.Value]; !ok {
+				obj.keys = append(obj.keys, key.Value)
+			}
+			obj.members[key.Value] = elem
+		}
+		m.value = obj
+	case yaml.SequenceNode:
+		elems := make([]*member, len(n.Content))
+		for i, c := range n.Content {
+			var err error
+			if elems[i], err = yamlMember(c); err != nil {
+				return nil, err
+			}
+		}
+		m.value = elems
+	default:
+		var value interface{}
+		if err := n.Decode(&value); err != nil {
+			return nil, &PositionError{Position{Line: n.Line}, fmt.Errorf("cannot parse YAML: %w", err)}
+		}
+		// other types, e.g. timestamps, aren't supported and are rejected when the values are parsed
+		switch v := value.(type) {
+		case int:
+			m.value = float64(v)
+		case int64:
+			m.value = float64(v)
+		case uint64:
+			m.value = float64(v)
+		default:
+			m.value = v
+		}
+	}
+	return m, nil
+}
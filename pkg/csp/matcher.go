@@ -0,0 +1,340 @@
+package csp
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+
+	"github.com/nilsbu/arch/pkg/graph"
+)
+
+// Matcher is a Check that matches constraint graphs onto an architecture.
+// The first graph is the architecture, all following ones are constraint graphs. Every child of the root of a
+// constraint graph must be matched onto a node of the architecture. Nodes that are adjacent in a constraint graph must be
+// matched onto adjacent nodes. No two nodes may be matched onto the same node or onto nodes that are ancestors of one
+// another.
+//
+// The search is a backtracking search in the spirit of VF2. The next node to be matched is the one with the fewest
+// remaining candidates, preferring nodes that are adjacent to ones that are matched already. Candidates are filtered by
+// name and by the number of neighbours up front. After every assignment, the candidates of the remaining nodes are
+// reduced to those that are still compatible (forward checking).
+type Matcher struct {
+	// ShareNodes allows nodes from different constraint graphs to be matched onto the same node or onto nodes that are
+	// ancestors of one another. Within a constraint graph, nodes are never shared.
+	ShareNodes bool
+}
+
+func (m *Matcher) Match(ctx context.Context, graphs []*graph.Graph) (ok bool, matches []graph.NodeIndex, err error) {
+	err = m.Enumerate(ctx, graphs, func(solution []graph.NodeIndex) bool {
+		ok, matches = true, solution
+		return false
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	return ok, matches, nil
+}
+
+// Enumerate calls yield for every solution until it returns false. Solutions are produced in the same order in which
+// the search encounters them, so the first one is the one that Match() returns.
+func (m *Matcher) Enumerate(
+	ctx context.Context, graphs []*graph.Graph, yield func(matches []graph.NodeIndex) bool,
+) error {
+	if len(graphs) < 2 {
+		yield(nil)
+		return nil
+	}
+
+	p := newProblem(ctx, graphs, m.ShareNodes)
+	return p.solve(func(assignment []int) bool {
+		return yield(p.matches(assignment))
+	})
+}
+
+// problem is the state of a single call to Match().
+type problem struct {
+	ctx   context.Context
+	share bool
+
+	// nodes are all nodes of the architecture in breadth-first order
+	nodes []graph.NodeIndex
+	// adjacent[i] contains the nodes that are adjacent to nodes[i]
+	adjacent []bitset
+	degree   []int
+	// enter and leave are the times of a depth-first traversal at which a node was entered and left
+	enter, leave []int
+
+	vars []variable
+}
+
+// A variable is a node of a constraint graph that has to be matched.
+type variable struct {
+	graph     int
+	neighbors []int
+	domain    []int
+}
+
+func newProblem(ctx context.Context, graphs []*graph.Graph, share bool) *problem {
+	p := &problem{
+		ctx:   ctx,
+		share: share,
+		nodes: getAllChildren(graphs[0]),
+	}
+
+	lookup := make(map[graph.NodeIndex]int, len(p.nodes))
+	for i, nidx := range p.nodes {
+		lookup[nidx] = i
+	}
+
+	p.adjacent = make([]bitset, len(p.nodes))
+	for i := range p.nodes {
+		p.adjacent[i] = newBitset(len(p.nodes))
+	}
+	for i, nidx := range p.nodes {
+		for _, j := range neighbors(graphs[0], nidx, lookup) {
+			p.adjacent[i].set(j)
+			p.adjacent[j].set(i)
+		}
+	}
+	p.degree = make([]int, len(p.nodes))
+	for i := range p.nodes {
+		p.degree[i] = p.adjacent[i].count()
+	}
+
+	p.enter = make([]int, len(p.nodes))
+	p.leave = make([]int, len(p.nodes))
+	clock := 0
+	var traverse func(nidx graph.NodeIndex)
+	traverse = func(nidx graph.NodeIndex) {
+		i := lookup[nidx]
+		p.enter[i] = clock
+		clock++
+		for _, cnidx := range graphs[0].Children(nidx) {
+			traverse(cnidx)
+		}
+		p.leave[i] = clock
+		clock++
+	}
+	traverse(graph.NodeIndex{})
+
+	for k, g := range graphs[1:] {
+		nidxs := g.Children(graph.NodeIndex{})
+		offset := len(p.vars)
+		vlookup := make(map[graph.NodeIndex]int, len(nidxs))
+		for i, nidx := range nidxs {
+			vlookup[nidx] = offset + i
+		}
+
+		for _, nidx := range nidxs {
+			v := variable{graph: k}
+			for _, j := range neighbors(g, nidx, vlookup) {
+				v.neighbors = appendUnique(v.neighbors, j)
+			}
+			for i, anidx := range p.nodes {
+				if couldBe(graphs[0].Node(anidx).Properties, g.Node(nidx).Properties) &&
+					p.degree[i] >= len(v.neighbors) {
+					v.domain = append(v.domain, i)
+				}
+			}
+			p.vars = append(p.vars, v)
+		}
+	}
+
+	// adjacency is symmetric, but edges may only be registered with one of the nodes
+	for i, v := range p.vars {
+		for _, j := range v.neighbors {
+			p.vars[j].neighbors = appendUnique(p.vars[j].neighbors, i)
+		}
+	}
+
+	return p
+}
+
+// neighbors returns the indices of nodes that are linked to nidx, as far as lookup contains them.
+func neighbors(g *graph.Graph, nidx graph.NodeIndex, lookup map[graph.NodeIndex]int) []int {
+	var out []int
+	for _, eidx := range g.Node(nidx).Edges {
+		enidxs := g.Nodes(eidx)
+
+		otherIdx := 0
+		for _, nidx2 := range enidxs[0] {
+			if nidx == nidx2 {
+				otherIdx = 1
+				break
+			}
+		}
+
+		for _, onidx := range enidxs[otherIdx] {
+			if i, ok := lookup[onidx]; ok {
+				out = append(out, i)
+			}
+		}
+	}
+	return out
+}
+
+func appendUnique(is []int, i int) []int {
+	for _, j := range is {
+		if i == j {
+			return is
+		}
+	}
+	return append(is, i)
+}
+
+// solve calls visit for every assignment that satisfies all constraints until it returns false.
+// assignment[i] is the index of the node in p.nodes that p.vars[i] is matched onto.
+func (p *problem) solve(visit func(assignment []int) bool) error {
+	assignment := make([]int, len(p.vars))
+	assigned := make([]bool, len(p.vars))
+	domains := make([][]int, len(p.vars))
+	for i, v := range p.vars {
+		domains[i] = v.domain
+	}
+
+	_, err := p.search(assignment, assigned, domains, len(p.vars), visit)
+	return err
+}
+
+func (p *problem) search(
+	assignment []int, assigned []bool, domains [][]int, left int, visit func(assignment []int) bool,
+) (cont bool, err error) {
+	if left == 0 {
+		return visit(assignment), nil
+	} else if err := p.ctx.Err(); err != nil {
+		return false, fmt.Errorf("matching was interrupted: %w", err)
+	}
+
+	x := p.next(assigned, domains)
+	assigned[x] = true
+	defer func() { assigned[x] = false }()
+
+	for _, a := range domains[x] {
+		assignment[x] = a
+		if reduced, ok := p.forwardCheck(x, a, assigned, domains); ok {
+			if cont, err := p.search(assignment, assigned, reduced, left-1, visit); !cont || err != nil {
+				return cont, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// next chooses the unassigned variable with the fewest candidates. Ties are broken in favour of variables with more
+// assigned neighbours, then more neighbours overall.
+func (p *problem) next(assigned []bool, domains [][]int) int {
+	best, bestLinked := -1, 0
+	for i, v := range p.vars {
+		if assigned[i] {
+			continue
+		}
+
+		linked := 0
+		for _, j := range v.neighbors {
+			if assigned[j] {
+				linked++
+			}
+		}
+
+		if best == -1 ||
+			len(domains[i]) < len(domains[best]) ||
+			len(domains[i]) == len(domains[best]) && (linked > bestLinked ||
+				linked == bestLinked && len(v.neighbors) > len(p.vars[best].neighbors)) {
+			best, bestLinked = i, linked
+		}
+	}
+	return best
+}
+
+// forwardCheck removes candidates from unassigned variables that are incompatible with x being assigned a.
+// ok is false if a variable is left without candidates.
+func (p *problem) forwardCheck(x, a int, assigned []bool, domains [][]int) (reduced [][]int, ok bool) {
+	reduced = make([][]int, len(domains))
+	copy(reduced, domains)
+
+	isNeighbor := make(map[int]bool, len(p.vars[x].neighbors))
+	for _, y := range p.vars[x].neighbors {
+		isNeighbor[y] = true
+	}
+
+	for y := range p.vars {
+		if assigned[y] {
+			continue
+		}
+
+		exclusive := !p.share || p.vars[x].graph == p.vars[y].graph
+		if !exclusive && !isNeighbor[y] {
+			continue
+		}
+
+		domain := make([]int, 0, len(domains[y]))
+		for _, b := range domains[y] {
+			if exclusive && p.related(a, b) {
+				continue
+			} else if isNeighbor[y] && !p.adjacent[a].has(b) {
+				continue
+			}
+			domain = append(domain, b)
+		}
+		if len(domain) == 0 {
+			return nil, false
+		}
+		reduced[y] = domain
+	}
+	return reduced, true
+}
+
+// related checks if two nodes are identical or one is an ancestor of the other.
+func (p *problem) related(a, b int) bool {
+	return p.enter[a] <= p.enter[b] && p.leave[b] <= p.leave[a] ||
+		p.enter[b] <= p.enter[a] && p.leave[a] <= p.leave[b]
+}
+
+func (p *problem) matches(assignment []int) []graph.NodeIndex {
+	matches := make([]graph.NodeIndex, len(assignment))
+	for i, a := range assignment {
+		matches[i] = p.nodes[a]
+	}
+	return matches
+}
+
+func getAllChildren(g *graph.Graph) []graph.NodeIndex {
+	nidxs := []graph.NodeIndex{{}}
+	for i := 0; i < len(nidxs); i++ {
+		nidxs = append(nidxs, g.Children(nidxs[i])...)
+	}
+	return nidxs
+}
+
+func couldBe(a, b graph.Properties) bool {
+	// TODO find a better place for this
+	if bname, ok := b["name"]; !ok {
+		return true
+	} else if aname, ok := a["name"]; !ok {
+		return false
+	} else {
+		return aname == bname
+	}
+}
+
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (b bitset) set(i int) {
+	b[i/64] |= 1 << (i % 64)
+}
+
+func (b bitset) has(i int) bool {
+	return b[i/64]&(1<<(i%64)) != 0
+}
+
+func (b bitset) count() int {
+	n := 0
+	for _, word := range b {
+		n += bits.OnesCount64(word)
+	}
+	return n
+}
@@ -1,15 +1,17 @@
 package csp_test
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/nilsbu/arch/pkg/csp"
 	"github.com/nilsbu/arch/pkg/graph"
 )
 
-func TestCentipedeMatch(t *testing.T) {
+func TestMatcherMatch(t *testing.T) {
 	for _, c := range []struct {
 		name    string
 		graphs  []func() *graph.Graph
@@ -268,7 +270,7 @@ func TestCentipedeMatch(t *testing.T) {
 				graphs[i] = f()
 			}
 
-			if ok, matches, err := (&csp.Centipede{}).Match(graphs); err != nil && c.err == nil {
+			if ok, matches, err := (&csp.Matcher{}).Match(context.Background(), graphs); err != nil && c.err == nil {
 				t.Errorf("unexpected error: %v", err)
 			} else if err == nil && c.err != nil {
 				t.Errorf("expected error but non ocurred")
@@ -288,8 +290,95 @@ func TestCentipedeMatch(t *testing.T) {
 	}
 }
 
+func TestMatcherMatchSeveralGraphs(t *testing.T) {
+	named := func(names ...string) func() *graph.Graph {
+		return func() *graph.Graph {
+			g := graph.New(nil)
+			for _, name := range names {
+				nidx, _ := g.Add(graph.NodeIndex{})
+				g.Node(nidx).Properties["name"] = name
+			}
+			return g
+		}
+	}
+	nested := func() *graph.Graph {
+		g := graph.New(nil)
+		n10, _ := g.Add(graph.NodeIndex{})
+		g.Node(n10).Properties["name"] = "a"
+		n20, _ := g.Add(n10)
+		g.Node(n20).Properties["name"] = "c"
+		return g
+	}
+
+	for _, c := range []struct {
+		name    string
+		share   bool
+		graphs  []func() *graph.Graph
+		ok      bool
+		matches []graph.NodeIndex
+	}{
+		{
+			"third graph is checked",
+			false,
+			[]func() *graph.Graph{named("a", "b"), named("a"), named("c")},
+			false, nil,
+		},
+		{
+			"all graphs match",
+			false,
+			[]func() *graph.Graph{named("a", "b", "c"), named("a", "c"), named("b")},
+			true, []graph.NodeIndex{{1, 0}, {1, 2}, {1, 1}},
+		},
+		{
+			"graphs can't share nodes by default",
+			false,
+			[]func() *graph.Graph{named("a", "b"), named("a"), named("a")},
+			false, nil,
+		},
+		{
+			"graphs may share nodes",
+			true,
+			[]func() *graph.Graph{named("a", "b"), named("a"), named("a")},
+			true, []graph.NodeIndex{{1, 0}, {1, 0}},
+		},
+		{
+			"nodes within a graph are never shared",
+			true,
+			[]func() *graph.Graph{named("a", "b"), named("a", "a"), named("b")},
+			false, nil,
+		},
+		{
+			"graphs can't use ancestors by default",
+			false,
+			[]func() *graph.Graph{nested, named("a"), named("c")},
+			false, nil,
+		},
+		{
+			"graphs may use ancestors when sharing",
+			true,
+			[]func() *graph.Graph{nested, named("a"), named("c")},
+			true, []graph.NodeIndex{{1, 0}, {2, 0}},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			graphs := make([]*graph.Graph, len(c.graphs))
+			for i, f := range c.graphs {
+				graphs[i] = f()
+			}
+
+			if ok, matches, err := (&csp.Matcher{ShareNodes: c.share}).Match(context.Background(), graphs); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if c.ok != ok {
+				t.Errorf("expected ok to be %v", c.ok)
+			} else if ok && !reflect.DeepEqual(c.matches, matches) {
+				t.Errorf("matches don't match:\nexpect: %v\nactual: %v", c.matches, matches)
+			}
+		})
+	}
+}
+
 func TestResetNodes(t *testing.T) {
-	c := &csp.Centipede{}
+	c := &csp.Matcher{}
 	g1 := graph.New(nil)
 	g1.Add(graph.NodeIndex{})
 	g1.Add(graph.NodeIndex{})
@@ -297,7 +386,100 @@ func TestResetNodes(t *testing.T) {
 	g2 := graph.New(nil)
 	g2.Add(graph.NodeIndex{})
 
-	c.Match([]*graph.Graph{graph.New(nil), g1})
-	c.Match([]*graph.Graph{graph.New(nil), g2})
+	c.Match(context.Background(), []*graph.Graph{graph.New(nil), g1})
+	c.Match(context.Background(), []*graph.Graph{graph.New(nil), g2})
 	// nothing to check, if it doesn't crash, reset worked
 }
+
+// grid creates an architecture with w x h rooms where neighbouring rooms are linked.
+// All rooms are called "room", except for the last one, which is a "kitchen".
+func grid(w, h int) *graph.Graph {
+	g := graph.New(nil)
+	nidxs := make([]graph.NodeIndex, w*h)
+	for i := range nidxs {
+		nidxs[i], _ = g.Add(graph.NodeIndex{})
+		g.Node(nidxs[i]).Properties["name"] = "room"
+		if x := i % w; x > 0 {
+			g.Link(nidxs[i-1], nidxs[i])
+		}
+		if i >= w {
+			g.Link(nidxs[i-w], nidxs[i])
+		}
+	}
+	g.Node(nidxs[len(nidxs)-1]).Properties["name"] = "kitchen"
+	return g
+}
+
+// path creates a constraint graph with n linked rooms that ends in a kitchen.
+func path(n int) *graph.Graph {
+	g := graph.New(nil)
+	var prev graph.NodeIndex
+	for i := 0; i < n; i++ {
+		nidx, _ := g.Add(graph.NodeIndex{})
+		g.Node(nidx).Properties["name"] = "room"
+		if i > 0 {
+			g.Link(prev, nidx)
+		}
+		prev = nidx
+	}
+	g.Node(prev).Properties["name"] = "kitchen"
+	return g
+}
+
+func TestMatcherScales(t *testing.T) {
+	arch := grid(30, 30)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ok, matches, err := (&csp.Matcher{ShareNodes: true}).Match(ctx, []*graph.Graph{arch, path(100), path(3)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if !ok {
+		t.Fatal("should have matched")
+	}
+
+	if len(matches) != 100+3 {
+		t.Fatalf("expected %v matches but got %v", 100+3, len(matches))
+	}
+	used := map[graph.NodeIndex]bool{}
+	for _, nidx := range matches[:100] {
+		if used[nidx] {
+			t.Errorf("node %v used twice", nidx)
+		}
+		used[nidx] = true
+	}
+}
+
+func TestMatcherCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := (&csp.Matcher{}).Match(ctx, []*graph.Graph{grid(3, 3), path(3)})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v but got %v", context.Canceled, err)
+	}
+}
+
+func TestMatcherEnumerate(t *testing.T) {
+	arch := grid(2, 2)
+	var solutions [][]graph.NodeIndex
+	err := (&csp.Matcher{}).Enumerate(context.Background(), []*graph.Graph{arch, path(2)}, func(m []graph.NodeIndex) bool {
+		solutions = append(solutions, m)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the kitchen has two neighbouring rooms
+	expect := [][]graph.NodeIndex{{{1, 1}, {1, 3}}, {{1, 2}, {1, 3}}}
+	if !reflect.DeepEqual(expect, solutions) {
+		t.Errorf("wrong solutions:\nexpect: %v\nactual: %v", expect, solutions)
+	}
+
+	_, first, _ := (&csp.Matcher{}).Match(context.Background(), []*graph.Graph{arch, path(2)})
+	if !reflect.DeepEqual(expect[0], first) {
+		t.Errorf("Match() should return the first solution but returned %v", first)
+	}
+}
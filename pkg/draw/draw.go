@@ -11,6 +11,14 @@ import (
 
 var ErrInvalidGraph = errors.New("graph cannot be drawn")
 
+// doorTiles are the types of the tiles that doors of each kind are drawn with.
+var doorTiles = map[area.DoorKind]world.TileType{
+	area.Door:    world.Door,
+	area.Archway: world.Archway,
+	area.Gate:    world.Gate,
+	area.Locked:  world.LockedDoor,
+}
+
 func Draw(g *graph.Graph) (*world.Tiles, error) {
 	root := (*area.AreaNode)(g.Node(graph.NodeIndex{}))
 	rect := root.GetRect()
@@ -46,11 +54,19 @@ func draw(g *graph.Graph, nidx graph.NodeIndex, tiles *world.Tiles) error {
 
 	for _, eidx := range a.Edges {
 		door := (*area.DoorEdge)(g.Edge(eidx))
-		pos := door.GetPos()
+		tile := world.Tile{Type: world.Free}
 		if render, ok := door.Properties["render"]; !ok || render.(bool) {
-			tiles.Set(pos.X, pos.Y, world.Tile{Type: world.Door})
-		} else {
-			tiles.Set(pos.X, pos.Y, world.Tile{Type: world.Free})
+			if t, ok := doorTiles[door.GetKind()]; ok {
+				tile.Type = t
+			} else {
+				return fmt.Errorf("%w: door %v has unknown kind %v", ErrInvalidGraph, eidx, door.GetKind())
+			}
+		}
+		opening := door.GetOpening()
+		for y := opening.Y0; y <= opening.Y1; y++ {
+			for x := opening.X0; x <= opening.X1; x++ {
+				tiles.Set(x, y, tile)
+			}
 		}
 	}
 
@@ -14,6 +14,9 @@ func TestDraw(t *testing.T) {
 	f := world.Tile{Type: world.Free}
 	w := world.Tile{Type: world.Wall}
 	d := world.Tile{Type: world.Door}
+	a := world.Tile{Type: world.Archway}
+	gt := world.Tile{Type: world.Gate}
+	l := world.Tile{Type: world.LockedDoor}
 	o := world.Tile{Type: world.Occupied, Texture: 1}
 	p := world.Tile{Type: world.Occupied, Texture: 2}
 
@@ -94,6 +97,98 @@ func TestDraw(t *testing.T) {
 			},
 			nil,
 		},
+		{
+			"wide archway",
+			func() *graph.Graph {
+				g := graph.New(nil)
+				node := (*area.AreaNode)(g.Node(graph.NodeIndex{}))
+				node.SetRect(area.Rectangle{X0: 0, Y0: 0, X1: 5, Y1: 4})
+				n1, _ := g.Add(graph.NodeIndex{})
+				node = (*area.AreaNode)(g.Node(n1))
+				node.SetRect(area.Rectangle{X0: 0, Y0: 0, X1: 3, Y1: 4})
+				n2, _ := g.Add(graph.NodeIndex{})
+				node = (*area.AreaNode)(g.Node(n2))
+				node.SetRect(area.Rectangle{X0: 3, Y0: 0, X1: 5, Y1: 4})
+				area.CreateOpening(g, n1, n2, .5, 3, area.Archway)
+
+				return g
+			},
+			[][]world.Tile{
+				{w, w, w, w, w, w},
+				{w, f, f, a, f, w},
+				{w, f, f, a, f, w},
+				{w, f, f, a, f, w},
+				{w, w, w, w, w, w},
+			},
+			nil,
+		},
+		{
+			"gate and locked door",
+			func() *graph.Graph {
+				g := graph.New(nil)
+				node := (*area.AreaNode)(g.Node(graph.NodeIndex{}))
+				node.SetRect(area.Rectangle{X0: 0, Y0: 0, X1: 5, Y1: 6})
+				n1, _ := g.Add(graph.NodeIndex{})
+				(*area.AreaNode)(g.Node(n1)).SetRect(area.Rectangle{X0: 0, Y0: 0, X1: 3, Y1: 6})
+				n2, _ := g.Add(graph.NodeIndex{})
+				(*area.AreaNode)(g.Node(n2)).SetRect(area.Rectangle{X0: 3, Y0: 0, X1: 5, Y1: 6})
+				area.CreateOpening(g, n1, n2, .25, 2, area.Gate)
+				area.CreateOpening(g, n1, n2, .75, 1, area.Locked)
+
+				return g
+			},
+			[][]world.Tile{
+				{w, w, w, w, w, w},
+				{w, f, f, w, f, w},
+				{w, f, f, gt, f, w},
+				{w, f, f, gt, f, w},
+				{w, f, f, w, f, w},
+				{w, f, f, l, f, w},
+				{w, w, w, w, w, w},
+			},
+			nil,
+		},
+		{
+			"wide door not rendered",
+			func() *graph.Graph {
+				g := graph.New(nil)
+				node := (*area.AreaNode)(g.Node(graph.NodeIndex{}))
+				node.SetRect(area.Rectangle{X0: 0, Y0: 0, X1: 5, Y1: 4})
+				n1, _ := g.Add(graph.NodeIndex{})
+				(*area.AreaNode)(g.Node(n1)).SetRect(area.Rectangle{X0: 0, Y0: 0, X1: 3, Y1: 4})
+				n2, _ := g.Add(graph.NodeIndex{})
+				(*area.AreaNode)(g.Node(n2)).SetRect(area.Rectangle{X0: 3, Y0: 0, X1: 5, Y1: 4})
+				area.CreateOpening(g, n1, n2, .5, 3, area.Locked)
+				g.Edge(g.Node(n1).Edges[0]).Properties["render"] = false
+
+				return g
+			},
+			[][]world.Tile{
+				{w, w, w, w, w, w},
+				{w, f, f, f, f, w},
+				{w, f, f, f, f, w},
+				{w, f, f, f, f, w},
+				{w, w, w, w, w, w},
+			},
+			nil,
+		},
+		{
+			"unknown kind of door",
+			func() *graph.Graph {
+				g := graph.New(nil)
+				node := (*area.AreaNode)(g.Node(graph.NodeIndex{}))
+				node.SetRect(area.Rectangle{X0: 0, Y0: 0, X1: 5, Y1: 4})
+				n1, _ := g.Add(graph.NodeIndex{})
+				(*area.AreaNode)(g.Node(n1)).SetRect(area.Rectangle{X0: 0, Y0: 0, X1: 3, Y1: 4})
+				n2, _ := g.Add(graph.NodeIndex{})
+				(*area.AreaNode)(g.Node(n2)).SetRect(area.Rectangle{X0: 3, Y0: 0, X1: 5, Y1: 4})
+				area.CreateOpening(g, n1, n2, .5, 1, area.DoorKind(42))
+
+				return g
+			},
+			nil,
+			draw.ErrInvalidGraph,
+		},
 		{
 			"render disabled",
 			func() *graph.Graph {
@@ -0,0 +1,42 @@
+package graph
+
+// Copy creates a graph with the same nodes and edges that shares nothing with g or its parents.
+// Properties are copied, but their values are not.
+func (g *Graph) Copy() *Graph {
+	out := New(nil)
+	out.nodes = nil
+
+	queue := []NodeIndex{{}}
+	for len(queue) > 0 {
+		nidx := queue[0]
+		queue = queue[1:]
+
+		node := g.Node(nidx)
+		cp := out.createNodeAt(nidx)
+		cp.Parent = node.Parent
+		cp.Edges = append([]EdgeIndex{}, node.Edges...)
+		for k, v := range node.Properties {
+			cp.Properties[k] = v
+		}
+
+		if children := g.Children(nidx); len(children) > 0 {
+			out.children[nidx] = children
+			queue = append(queue, children...)
+		}
+	}
+
+	for eidx := EdgeIndex(0); int(eidx) < g.countEdges(); eidx++ {
+		edge := &Edge{Properties: Properties{}}
+		for k, v := range g.Edge(eidx).Properties {
+			edge.Properties[k] = v
+		}
+		out.edges = append(out.edges, edge)
+
+		nodes := g.Nodes(eidx)
+		out.edgeNodes[eidx] = &edgeNodes{
+			Nodes: [2][]NodeIndex{append([]NodeIndex{}, nodes[0]...), append([]NodeIndex{}, nodes[1]...)},
+		}
+	}
+
+	return out
+}
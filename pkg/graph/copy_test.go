@@ -0,0 +1,41 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/nilsbu/arch/pkg/graph"
+	tg "github.com/nilsbu/arch/test/graph"
+)
+
+func TestCopy(t *testing.T) {
+	base := graph.New(nil)
+	n0, _ := base.Add(graph.NodeIndex{})
+	n1, _ := base.Add(graph.NodeIndex{})
+	eidx, _ := base.Link(n0, n1)
+	base.Edge(eidx).Properties["X"] = 1
+
+	g := graph.New(base)
+	n2, _ := g.Add(n1)
+	n3, _ := g.Add(n1)
+	g.Link(n2, n3)
+	g.InheritEdge(n1, n2, []graph.EdgeIndex{eidx})
+	g.Node(n3).Properties["name"] = "a"
+
+	cp := g.Copy()
+	if ok, msg := tg.AreEqual(g, cp); !ok {
+		t.Fatalf("copy differs: %v", msg)
+	}
+
+	cp.Node(n3).Properties["name"] = "b"
+	cp.Edge(eidx).Properties["X"] = 2
+	cp.Add(n3)
+	if name := g.Node(n3).Properties["name"]; name != "a" {
+		t.Errorf("node properties are shared")
+	}
+	if x := base.Edge(eidx).Properties["X"]; x != 1 {
+		t.Errorf("edge properties are shared")
+	}
+	if children := g.Children(n3); len(children) != 0 {
+		t.Errorf("children are shared")
+	}
+}
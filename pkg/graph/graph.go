@@ -188,14 +188,12 @@ func (g *Graph) InheritEdge(parent, nidx NodeIndex, edges []EdgeIndex) error {
 }
 
 // Link creates an edge between two nodes.
-// They must have the same parent and not be linked, already.
+// They must have the same parent. Nodes may be linked several times, each link creates a new edge.
 func (g *Graph) Link(a, b NodeIndex) (EdgeIndex, error) {
 	if nodeA, nodeB := g.Node(a), g.Node(b); nodeA == nil || nodeB == nil {
 		return -1, fmt.Errorf("%w: nodes must be created in the same graph as the edge", ErrIllegalAction)
 	} else if nodeA.Parent != nodeB.Parent {
 		return -1, fmt.Errorf("%w: nodes must have the same parent", ErrIllegalAction)
-	} else if g.linkExists(a, b) {
-		return -1, fmt.Errorf("%w: nodes are already linked", ErrIllegalAction)
 	} else {
 		eidx := EdgeIndex(g.countEdges())
 
@@ -214,16 +212,3 @@ func (g *Graph) Link(a, b NodeIndex) (EdgeIndex, error) {
 		return EdgeIndex(eidx), nil
 	}
 }
-
-// linkExists checks if two nodes that have the same parent are already linked. It will not look for edges between nodes
-// of different parents.
-func (g *Graph) linkExists(a, b NodeIndex) bool {
-	for _, en := range g.edgeNodes {
-		if (en.Nodes[0][0] == a && en.Nodes[1][0] == b) || (en.Nodes[0][0] == b && en.Nodes[1][0] == a) {
-			return true
-		}
-	}
-
-	// g.parent isn't tested since linkExists() is used in Link() and a link across graph instances isn't permitted
-	return false
-}
@@ -416,15 +416,16 @@ func TestAddWithoutParent(t *testing.T) {
 }
 
 func TestLinkeNodesTwice(t *testing.T) {
-	// TODO this should be legal
 	g := graph.New(nil)
 	n0, _ := g.Add(graph.NodeIndex{})
 	n1, _ := g.Add(graph.NodeIndex{})
-	g.Link(n0, n1)
-	if _, err := g.Link(n0, n1); err == nil {
-		t.Error("must return error")
-	} else if !errors.Is(err, graph.ErrIllegalAction) {
-		t.Error("error must be 'ErrIllegalAction'")
+	e0, _ := g.Link(n0, n1)
+	if e1, err := g.Link(n1, n0); err != nil {
+		t.Error("unexpected error:", err)
+	} else if e0 == e1 {
+		t.Error("second link must create a new edge")
+	} else if edges := g.Node(n0).Edges; !reflect.DeepEqual([]graph.EdgeIndex{e0, e1}, edges) {
+		t.Errorf("expected edges %v but got %v", []graph.EdgeIndex{e0, e1}, edges)
 	}
 }
 
@@ -61,6 +61,30 @@ func TestLeaves(t *testing.T) {
 			},
 			nil,
 		},
+		{
+			"two nodes linked twice",
+			func() *graph.Graph {
+				g := graph.New(nil)
+				n0, _ := g.Add(graph.NodeIndex{})
+				n1, _ := g.Add(graph.NodeIndex{})
+				e0, _ := g.Link(n0, n1)
+				g.Edge(e0).Properties["a"] = "b"
+				e1, _ := g.Link(n0, n1)
+				g.Edge(e1).Properties["a"] = "c"
+				return g
+			},
+			func() *graph.Graph {
+				g := graph.New(nil)
+				n0, _ := g.Add(graph.NodeIndex{})
+				n1, _ := g.Add(graph.NodeIndex{})
+				e0, _ := g.Link(n0, n1)
+				g.Edge(e0).Properties["a"] = "b"
+				e1, _ := g.Link(n0, n1)
+				g.Edge(e1).Properties["a"] = "c"
+				return g
+			},
+			nil,
+		},
 		{
 			"not fully inherited edge",
 			func() *graph.Graph {
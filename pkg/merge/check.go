@@ -1,9 +1,66 @@
 package merge
 
-import "github.com/nilsbu/arch/pkg/graph"
+import (
+	"context"
+	"errors"
 
+	"github.com/nilsbu/arch/pkg/graph"
+)
+
+// ErrCannotEnumerate is returned when matches have to be enumerated by a Check that isn't an Enumerator.
+var ErrCannotEnumerate = errors.New("check cannot enumerate matches")
+
+// A Check decides whether a set of graphs fits together.
+// The first graph is the architecture, the others are constraints. If the graphs fit, matches may contain the node of
+// the architecture that each child of the roots of the constraint graphs was matched onto. They are listed graph by
+// graph, in the order returned by Children(). A Check that doesn't determine matches returns nil.
+// With fewer than two graphs, there is nothing to check and the graphs fit.
+// Match must return as soon as possible when ctx is done. It then returns an error that wraps ctx.Err().
 type Check interface {
-	Match(graphs []*graph.Graph) (ok bool, matches []graph.NodeIndex, err error)
+	Match(ctx context.Context, graphs []*graph.Graph) (ok bool, matches []graph.NodeIndex, err error)
+}
+
+// An Enumerator is a Check that can list every way in which the graphs fit together.
+type Enumerator interface {
+	Check
+	// Enumerate calls yield with the matches of every solution until yield returns false. Matches have the same format
+	// as in Match() and every solution is distinct. yield may keep the matches.
+	Enumerate(ctx context.Context, graphs []*graph.Graph, yield func(matches []graph.NodeIndex) bool) error
 }
 
-// TODO doc: incl expeced behaviour for zero graphs
+// A Score rates the matches of constraint graphs onto an architecture. Higher is better.
+type Score func(graphs []*graph.Graph, matches []graph.NodeIndex) float64
+
+// All returns the matches of up to limit solutions. Values of limit less than 1 remove the limit.
+func All(
+	ctx context.Context, e Enumerator, graphs []*graph.Graph, limit int,
+) (solutions [][]graph.NodeIndex, err error) {
+	err = e.Enumerate(ctx, graphs, func(matches []graph.NodeIndex) bool {
+		solutions = append(solutions, matches)
+		return limit < 1 || len(solutions) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return solutions, nil
+}
+
+// Best returns the matches with the highest score among the first limit solutions. Values of limit less than 1 remove
+// the limit. If several solutions have the same score, the first one is returned. ok is false if there are no
+// solutions.
+func Best(
+	ctx context.Context, e Enumerator, graphs []*graph.Graph, score Score, limit int,
+) (ok bool, matches []graph.NodeIndex, value float64, err error) {
+	n := 0
+	err = e.Enumerate(ctx, graphs, func(candidate []graph.NodeIndex) bool {
+		if v := score(graphs, candidate); !ok || v > value {
+			ok, matches, value = true, candidate, v
+		}
+		n++
+		return limit < 1 || n < limit
+	})
+	if err != nil {
+		return false, nil, 0, err
+	}
+	return ok, matches, value, nil
+}
@@ -0,0 +1,92 @@
+package merge_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/nilsbu/arch/pkg/csp"
+	"github.com/nilsbu/arch/pkg/graph"
+	"github.com/nilsbu/arch/pkg/merge"
+)
+
+func rooms(n int) *graph.Graph {
+	g := graph.New(nil)
+	for i := 0; i < n; i++ {
+		g.Add(graph.NodeIndex{})
+	}
+	return g
+}
+
+func TestAll(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		limit int
+		n     int
+	}{
+		{"no limit", 0, 6},
+		{"limit", 4, 4},
+		{"limit above number of solutions", 10, 6},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			solutions, err := merge.All(context.Background(), &csp.Matcher{}, []*graph.Graph{rooms(3), rooms(2)}, c.limit)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(solutions) != c.n {
+				t.Fatalf("expected %v solutions but got %v", c.n, len(solutions))
+			}
+
+			seen := map[[2]graph.NodeIndex]bool{}
+			for _, matches := range solutions {
+				key := [2]graph.NodeIndex{matches[0], matches[1]}
+				if seen[key] {
+					t.Errorf("solution %v returned twice", matches)
+				}
+				seen[key] = true
+			}
+		})
+	}
+}
+
+func TestBest(t *testing.T) {
+	graphs := []*graph.Graph{rooms(3), rooms(2)}
+	// prefers the first match to lie far behind the second one
+	score := func(graphs []*graph.Graph, matches []graph.NodeIndex) float64 {
+		return float64(matches[0][1] - matches[1][1])
+	}
+
+	for _, c := range []struct {
+		name    string
+		limit   int
+		matches []graph.NodeIndex
+		value   float64
+	}{
+		{"no limit", 0, []graph.NodeIndex{{1, 2}, {1, 0}}, 2},
+		{"limit", 1, []graph.NodeIndex{{1, 0}, {1, 1}}, -1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ok, matches, value, err := merge.Best(context.Background(), &csp.Matcher{}, graphs, score, c.limit)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if !ok {
+				t.Fatal("expected a solution")
+			}
+			if !reflect.DeepEqual(c.matches, matches) {
+				t.Errorf("wrong matches:\nexpect: %v\nactual: %v", c.matches, matches)
+			}
+			if c.value != value {
+				t.Errorf("expected score %v but got %v", c.value, value)
+			}
+		})
+	}
+
+	t.Run("no solution", func(t *testing.T) {
+		ok, _, _, err := merge.Best(context.Background(), &csp.Matcher{}, []*graph.Graph{rooms(1), rooms(2)}, score, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		} else if ok {
+			t.Error("expected no solution")
+		}
+	})
+}
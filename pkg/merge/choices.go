@@ -2,136 +2,283 @@ package merge
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/nilsbu/arch/pkg/blueprint"
+	"github.com/nilsbu/arch/pkg/rule"
 )
 
-// TODO document all of this
+// A block is a blueprint that is resolved to a rule.
+// Every entry in params corresponds to one of the rule's child parameters. Each element in such a group becomes one
+// child node. path describes how the block was reached from the root, see Report.
 type block struct {
 	params    []group
 	blueprint *blueprint.Blueprint
+	path      string
+	variables []variable
+	// draws is true if the rule draws from distributions itself, see rule.Drawer
+	draws bool
 }
 
-func (b *block) n() int {
-	value := 1
-	for _, param := range b.params {
-		for _, iob := range param {
-			value *= iob.n()
+// A variable is a value of a block that is a distribution. Whenever the block is built, a value is drawn for it.
+// index is the position of the value among the values of property.
+type variable struct {
+	property     string
+	index        int
+	distribution blueprint.Distribution
+}
+
+// A group is a list of groupOrBlocks. Depending on context, it is interpreted differently: As a parameter of a block,
+// every element is a separate child. When referenced by name, the elements are alternatives.
+type group []*groupOrBlock
+
+// alternatives returns all blocks that can be chosen for the group when its elements are alternatives.
+// The weight of an element is split among the blocks it contains, proportional to their own weights.
+func (g group) alternatives() ([]*block, []float64) {
+	var blocks []*block
+	var weights []float64
+	for _, gob := range g {
+		bs, ws := gob.alternatives()
+		sum := 0.
+		for _, w := range ws {
+			sum += w
+		}
+		for i := range ws {
+			blocks = append(blocks, bs[i])
+			weights = append(weights, gob.weight*ws[i]/sum)
 		}
 	}
-	return value
+	return blocks, weights
 }
 
-type bpNode struct {
-	children [][]*bpNode
-	bp       *blueprint.Blueprint
+// A groupOrBlock is either a reference to a group or a block. Its weight only matters when it is an alternative.
+type groupOrBlock struct {
+	group  group
+	block  *block
+	weight float64
 }
 
-func (b *block) get(i int) *bpNode {
-	res := &bpNode{
-		bp: b.blueprint,
-	}
-	res.children = make([][]*bpNode, len(b.params))
-	for k, param := range b.params {
-		pnodes := make([]*bpNode, len(param))
-		for l, iob := range param {
-			x := iob.n()
-			j := i % x
-			i /= x
-			pnodes[l] = iob.get(j)
-		}
-		res.children[k] = pnodes
+// alternatives returns all blocks that can be chosen for a child node and their weights.
+func (gob groupOrBlock) alternatives() ([]*block, []float64) {
+	if gob.group != nil {
+		return gob.group.alternatives()
+	} else {
+		return []*block{gob.block}, []float64{1}
 	}
-	return res
 }
 
-type group []*groupOrBlock
+// An expansion turns blueprints into blocks. Since blueprints may refer to themselves, it counts how often each of
+// them occurs among the ancestors of a block. Once a blueprint would exceed its maximum depth, it is pruned: it is no
+// longer an alternative and blocks that need it as a child are pruned as well.
+type expansion struct {
+	resolver *Resolver
+	maxDepth int
+	depth    map[*blueprint.Blueprint]int
+	// open contains the references that are being expanded without having passed a block, names lists them in order
+	open  map[reference]bool
+	names []string
+}
 
-func (g group) n() int {
-	value := 0
-	for _, iob := range g {
-		value += iob.n()
+func newExpansion(resolver *Resolver, maxDepth int) *expansion {
+	return &expansion{
+		resolver: resolver,
+		maxDepth: maxDepth,
+		depth:    map[*blueprint.Blueprint]int{},
+		open:     map[reference]bool{},
 	}
-	return value
 }
-func (g group) get(i int) (bpn *bpNode) {
-	for _, iob := range g {
-		x := iob.n()
-		if i < x {
-			bpn = iob.get(i)
-			break
+
+// calcBlock creates the block for a blueprint. If it has to be pruned, it returns nil without an error.
+func (e *expansion) calcBlock(bp *blueprint.Blueprint, path string) (*block, error) {
+	name := bp.Values(e.resolver.Name)
+	if len(name) != 1 {
+		return nil, &blueprint.PositionError{Position: locate(bp, path),
+			Err: fmt.Errorf("%w: '%v' must have exactly one value", ErrInvalidBlueprint, e.resolver.Name)}
+	}
+
+	rule := e.resolver.Keys[name[0]]
+	if rule == nil {
+		pos, _ := bp.Position(e.resolver.Name)
+		pos.Path = path
+		return nil, &blueprint.PositionError{Position: pos,
+			Err: fmt.Errorf("%w: key '%v' is not defined", ErrInvalidBlueprint, name[0])}
+	}
+
+	if limit, err := e.limit(bp, name[0]); err != nil {
+		return nil, err
+	} else if e.depth[bp] > limit {
+		return nil, nil
+	}
+	e.depth[bp]++
+	defer func() { e.depth[bp]-- }()
+
+	// references are resolved anew below a block
+	open, names := e.open, e.names
+	e.open, e.names = map[reference]bool{}, nil
+	defer func() { e.open, e.names = open, names }()
+
+	blck := &block{
+		blueprint: bp,
+		path:      path,
+		variables: variables(bp, rule),
+		draws:     draws(bp, rule),
+	}
+	for _, param := range rule.ChildParams() {
+		if grp, err := e.calcGroup(bp, param, join(path, param)); err != nil {
+			return nil, err
 		} else {
-			i -= x
+			for _, gob := range grp {
+				if gob == nil {
+					return nil, nil
+				}
+			}
+			blck.params = append(blck.params, grp)
 		}
 	}
-	// Slightly awkward construction due to my obsession with 100% coverage. There's no realistic way to produce an
-	// error here, so I chose to break earlier and thus force to call the following line.
-	return
+	return blck, nil
 }
 
-type groupOrBlock struct {
-	group group
-	block *block
-}
+// limit returns how often a blueprint that uses the named rule may occur inside itself.
+func (e *expansion) limit(bp *blueprint.Blueprint, name string) (int, error) {
+	depth := e.maxDepth
+	if e.resolver.Depth != "" && bp.Values(e.resolver.Depth) != nil {
+		if own, err := bp.Int(e.resolver.Depth); err != nil {
+			return 0, err
+		} else if own < 0 {
+			pos, _ := bp.Position(e.resolver.Depth)
+			return 0, &blueprint.PositionError{Position: pos,
+				Err: fmt.Errorf("%w: '%v' must not be negative", ErrInvalidBlueprint, e.resolver.Depth)}
+		} else {
+			depth = own
+		}
+	}
 
-func (gob groupOrBlock) n() int {
-	if gob.group != nil {
-		return gob.group.n()
-	} else {
-		return gob.block.n()
+	if limit, ok := e.resolver.Depths[name]; ok && limit < depth {
+		return limit, nil
 	}
+	return depth, nil
 }
 
-func (gob groupOrBlock) get(i int) *bpNode {
-	if gob.group != nil {
-		return gob.group.get(i)
+// variables finds the distributions among the values that a rule reads. If the rule doesn't declare them, all
+// properties of the blueprint itself are considered. Distributions that the rule draws from itself are left out.
+func variables(bp *blueprint.Blueprint, r rule.Rule) []variable {
+	var properties []string
+	if d, ok := r.(rule.Declarer); ok {
+		for _, param := range d.ValueParams() {
+			if !param.Drawn {
+				properties = append(properties, param.Name)
+			}
+		}
 	} else {
-		return gob.block.get(i)
+		properties = bp.Properties()
+		sort.Strings(properties)
 	}
-}
 
-func calcBlock(bp *blueprint.Blueprint, resolver *Resolver) (*block, error) {
-	if name := bp.Values(resolver.Name); len(name) != 1 {
-		return nil, fmt.Errorf("%w: ", ErrInvalidBlueprint)
-	} else {
-		blck := &block{
-			blueprint: bp,
+	var vars []variable
+	for _, property := range properties {
+		for i, v := range bp.TypedValues(property) {
+			if d, ok := v.Distribution(); ok {
+				vars = append(vars, variable{property, i, d})
+			}
 		}
+	}
+	return vars
+}
 
-		rule := resolver.Keys[name[0]]
-		if rule == nil {
-			return nil, fmt.Errorf("%w: key '%v' is not defined", ErrInvalidBlueprint, name[0])
+// draws checks if a rule draws from distributions among its values itself.
+func draws(bp *blueprint.Blueprint, r rule.Rule) bool {
+	_, drawer := r.(rule.Drawer)
+	d, ok := r.(rule.Declarer)
+	if !drawer || !ok {
+		return false
+	}
+	for _, param := range d.ValueParams() {
+		if !param.Drawn {
+			continue
 		}
-		for _, param := range rule.ChildParams() {
-			if grp, err := calcGroup(bp, param, resolver); err != nil {
-				return nil, err
-			} else {
-				blck.params = append(blck.params, grp)
+		for _, v := range bp.TypedValues(param.Name) {
+			if _, ok := v.Distribution(); ok {
+				return true
 			}
 		}
-		return blck, nil
 	}
+	return false
 }
 
-func calcGroup(bp *blueprint.Blueprint, property string, resolver *Resolver) (group, error) {
+// calcGroup creates the elements of a group. Elements that are pruned are nil.
+func (e *expansion) calcGroup(bp *blueprint.Blueprint, property, path string) (group, error) {
 	values := bp.Values(property)
+	weights := bp.Weights(property)
 	group := make(group, len(values))
 	for i, opt := range values {
 		var err error
-		if group[i], err = calcGroupOrBlock(bp, opt, resolver); err != nil {
+		if group[i], err = e.calcGroupOrBlock(bp, opt, path); err != nil {
 			return nil, err
+		} else if group[i] != nil {
+			group[i].weight = weights[i]
 		}
 	}
 	return group, nil
 }
 
-func calcGroupOrBlock(bp *blueprint.Blueprint, property string, resolver *Resolver) (*groupOrBlock, error) {
+// calcGroupOrBlock resolves a value. It returns nil if nothing is left after pruning.
+func (e *expansion) calcGroupOrBlock(bp *blueprint.Blueprint, property, path string) (*groupOrBlock, error) {
 	switch property[0] {
 	case '*':
-		blck, err := calcBlock(bp.Child(property), resolver)
-		return &groupOrBlock{block: blck}, err
+		if blck, err := e.calcBlock(bp.Child(property), path); err != nil || blck == nil {
+			return nil, err
+		} else {
+			return &groupOrBlock{block: blck}, nil
+		}
 	default:
-		choice, err := calcGroup(bp, property, resolver)
-		return &groupOrBlock{group: choice}, err
+		key := reference{bp, property}
+		if e.open[key] {
+			return nil, &blueprint.PositionError{Position: locate(bp, path),
+				Err: fmt.Errorf("%w: reference cycle %v", ErrInvalidBlueprint,
+					strings.Join(append(e.names[indexOf(e.names, property):], property), " -> "))}
+		}
+		e.open[key], e.names = true, append(e.names, property)
+		defer func() { delete(e.open, key); e.names = e.names[:len(e.names)-1] }()
+
+		choice, err := e.calcGroup(bp, property, join(path, property))
+		if err != nil {
+			return nil, err
+		}
+		// the elements are alternatives, so only the pruned ones are dropped
+		alternatives := group{}
+		for _, gob := range choice {
+			if gob != nil {
+				alternatives = append(alternatives, gob)
+			}
+		}
+		if len(alternatives) == 0 && len(choice) > 0 {
+			return nil, nil
+		}
+		return &groupOrBlock{group: alternatives}, nil
+	}
+}
+
+func indexOf(strs []string, str string) int {
+	for i, s := range strs {
+		if s == str {
+			return i
+		}
+	}
+	return -1
+}
+
+// locate returns the position of a blueprint with the path through which it was reached.
+func locate(bp *blueprint.Blueprint, path string) blueprint.Position {
+	pos := bp.Location()
+	pos.Path = path
+	return pos
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	} else {
+		return path + "/" + name
 	}
 }
@@ -1,8 +1,10 @@
 package merge
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 
 	"github.com/nilsbu/arch/pkg/blueprint"
 	"github.com/nilsbu/arch/pkg/graph"
@@ -15,76 +17,405 @@ var ErrInvalidBlueprint = errors.New("invalid blueprint")
 
 var ErrNoSolution = errors.New("no solution found")
 
-func Build(bps []*blueprint.Blueprint, check Check, resolver *Resolver, shuffle Shuffle) (*graph.Graph, error) {
-	choices := make([]*block, len(bps))
-	ns := make([]int, len(bps))
+// errRejected is returned through the search when a complete set of graphs was rejected by the Check.
+var errRejected = errors.New("rejected by check")
+
+// subtreeError signals that a node cannot be built with the chosen block, no matter what is chosen elsewhere.
+// Since the area a node receives only depends on its ancestors, retrying other options for its siblings is pointless.
+type subtreeError struct {
+	nidx graph.NodeIndex
+	err  error
+}
+
+func (e *subtreeError) Error() string {
+	return fmt.Sprintf("node %v cannot be built: %v", e.nidx, e.err)
+}
+
+func (e *subtreeError) Unwrap() error {
+	return e.err
+}
+
+// Build creates an architecture graph from blueprints.
+// The blueprints are validated first, see Validate.
+// The graphs are constructed top-down. Whenever a node is created, one of the options for it is chosen and prepared
+// right away. If that fails, only the options of that node are retried. When none of them works, the search backtracks
+// to its parent. Combinations of blocks and node states that failed are remembered and not attempted again.
+// Once complete graphs for all blueprints exist, they are passed to check. If they are rejected, the search continues
+// with the most recent choice.
+//
+// shuffle determines the order in which the options of a node are tried. All random decisions are drawn from rnd.
+// Given the same blueprints and an identically seeded rnd, the result is always the same.
+//
+// The search stops when ctx is done or a limit set through opts is reached. A *BudgetError is returned in that case.
+//
+// The first blueprint describes the architecture, the others constraints. The matches returned by check are included
+// in the Result and the nodes of the architecture are annotated with the requirements they fulfil. With the Optimize
+// option, several architectures are compared by the score of their best matches.
+func Build(
+	ctx context.Context,
+	bps []*blueprint.Blueprint, check Check, resolver *Resolver, shuffle Shuffle, rnd *rand.Rand,
+	opts ...Option,
+) (*Result, error) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	for _, bp := range bps {
+		if err := Validate(bp, resolver); err != nil {
+			return nil, err
+		}
+	}
+
+	blocks := make([]*block, len(bps))
 	for i, bp := range bps {
-		if block, err := calcBlock(bp, resolver); err != nil {
+		if block, err := newExpansion(resolver, o.maxDepth).calcBlock(bp, ""); err != nil {
 			return nil, err
+		} else if block == nil {
+			return nil, &blueprint.PositionError{Position: bp.Location(),
+				Err: fmt.Errorf("%w: blueprint cannot be completed within its maximum depth", ErrInvalidBlueprint)}
 		} else {
-			choices[i] = block
-			ns[i] = choices[i].n()
+			blocks[i] = block
 		}
 	}
 
-	for _, is := range shuffle(ns) {
+	var opt *optimizer
+	if o.score != nil {
+		if e, ok := check.(Enumerator); !ok {
+			return nil, fmt.Errorf("%w: cannot optimize with %T", ErrCannotEnumerate, check)
+		} else {
+			opt = &optimizer{enumerator: e, score: o.score, compare: o.compare, maxMatches: o.maxMatches}
+		}
+	}
 
-		gs := make([]*graph.Graph, len(choices))
-		ok := true
-		for j, choices := range choices {
+	b := &builder{
+		ctx:      ctx,
+		resolver: resolver,
+		shuffle:  shuffle,
+		rnd:      rnd,
+		failed:   map[failure]bool{},
+		report:   newReport(),
+		samples:  o.samples,
+	}
+	if b.samples < 1 {
+		b.samples = defaultSamples
+	}
 
-			gs[j] = graph.New(nil)
-			if err := parse(gs[j], graph.NodeIndex{}, choices.get(is[j]), resolver); errors.Is(err, rule.ErrInvalidGraph) {
-				ok = false
-				break
-			} else if err != nil {
-				return nil, err
+	gs := make([]*graph.Graph, len(blocks))
+	var res *Result
+	var buildFrom func(i int) error
+	buildFrom = func(i int) error {
+		if i == len(blocks) {
+			if o.maxCandidates > 0 && b.report.Candidates >= o.maxCandidates {
+				return errTooManyCandidates
+			}
+			b.report.Candidates++
+			if opt != nil {
+				if ok, err := opt.consider(ctx, gs); err != nil {
+					return err
+				} else if !ok {
+					b.report.Rejected++
+					return errRejected
+				} else if opt.done() {
+					return errComparedEnough
+				} else {
+					return errRejected
+				}
+			} else if ok, matches, err := check.Match(ctx, gs); err != nil {
+				return err
+			} else if !ok {
+				b.report.Rejected++
+				return errRejected
+			} else {
+				res, err = newResult(gs, matches)
+				return err
 			}
 		}
-		if !ok {
-			continue
+
+		return b.build(graph.New(nil), graph.NodeIndex{}, blocks[i], func(g *graph.Graph) error {
+			gs[i] = g
+			return buildFrom(i + 1)
+		})
+	}
+
+	err := buildFrom(0)
+	stopped := errors.Is(err, errTooManyCandidates) || ctx.Err() != nil
+	if opt != nil && opt.best != nil && (stopped || isRetryable(err) || errors.Is(err, errComparedEnough)) {
+		return opt.best, nil
+	} else if err == nil {
+		return res, nil
+	} else if stopped {
+		return nil, &BudgetError{Candidates: b.report.Candidates, Cause: err, Report: b.report}
+	} else if isRetryable(err) {
+		return nil, &NoSolutionError{Report: b.report}
+	} else {
+		return nil, err
+	}
+}
+
+type builder struct {
+	ctx      context.Context
+	resolver *Resolver
+	shuffle  Shuffle
+	rnd      *rand.Rand
+	failed   map[failure]bool
+	report   *Report
+	samples  int
+}
+
+// failure identifies an attempt to build a node with a block.
+// state contains everything a rule may rely on that was set up by the node's ancestors. Under the assumption that
+// rules are deterministic, an attempt that failed once will always fail.
+type failure struct {
+	block *block
+	state string
+}
+
+// build prepares the node nidx using blk and constructs its subtree afterwards. Every time the subtree is complete,
+// next is called with the graph that contains it. build returns nil as soon as next did. Otherwise the error of the
+// last attempt is returned. If blk contains distributions, other values for them are tried before giving up.
+func (b *builder) build(
+	g *graph.Graph, nidx graph.NodeIndex, blk *block, next func(g *graph.Graph) error,
+) (err error) {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+
+	key := failure{block: blk, state: nodeState(g, nidx)}
+	if b.failed[key] {
+		b.report.Skipped++
+		return &subtreeError{nidx, fmt.Errorf("%w: already failed", rule.ErrInvalidGraph)}
+	}
+
+	restore := snapshot(g, nidx)
+	reached := false
+	defer func() {
+		if err != nil {
+			restore()
+			if !reached && isRetryable(err) {
+				b.failed[key] = true
+			}
 		}
+	}()
 
-		// TODO use matches (and decide what to do when none are given)
-		if ok, _, err := check.Match(gs); err != nil {
-			return nil, err
-		} else if ok {
-			return gs[0], nil
+	variants := b.variants(blk)
+	for bp, ok := variants(); ok; bp, ok = variants() {
+		if err != nil {
+			restore()
 		}
-	}
+		err = b.attempt(g, nidx, blk, bp, func(g *graph.Graph) error {
+			reached = true
+			return next(g)
+		})
 
-	return nil, ErrNoSolution
+		var se *subtreeError
+		if err == nil || !(errors.As(err, &se) && se.nidx == nidx || errors.Is(err, errRejected)) {
+			return err
+		}
+	}
+	return err
 }
 
-func parse(g *graph.Graph, nidx graph.NodeIndex, choice *bpNode, resolver *Resolver) error {
-	node := g.Node(nidx)
-	node.Properties["name"] = choice.bp.Values(resolver.Name)[0]
+// attempt prepares the node nidx using blk with the values in bp and builds its subtree.
+func (b *builder) attempt(
+	g *graph.Graph, nidx graph.NodeIndex, blk *block, bp *blueprint.Blueprint, next func(g *graph.Graph) error,
+) error {
+	sub := graph.New(g)
+	node := sub.Node(nidx)
+	node.Properties["name"] = blk.blueprint.Values(b.resolver.Name)[0]
 
-	nidxs := map[string][]graph.NodeIndex{}
-	namedChoices := map[string][]*bpNode{}
-	name := g.Node(nidx).Properties["name"].(string)
-	r := resolver.Keys[name]
+	name := node.Properties["name"].(string)
+	r := b.resolver.Keys[name]
 	names := r.ChildParams()
 
-	for i, params := range choice.children {
-		name := names[i]
-		for _, grandchild := range params {
-			gcnidx, _ := g.Add(nidx)
-			namedChoices[name] = append(namedChoices[name], grandchild)
-			nidxs[name] = append(nidxs[name], gcnidx)
+	nidxs := map[string][]graph.NodeIndex{}
+	var slots []slot
+	for i, param := range blk.params {
+		for _, gob := range param {
+			cnidx, _ := sub.Add(nidx)
+			nidxs[names[i]] = append(nidxs[names[i]], cnidx)
+			blocks, weights := gob.alternatives()
+			slots = append(slots, slot{cnidx, blocks, weights})
 		}
 	}
 
-	if err := r.PrepareGraph(g, nidx, nidxs, choice.bp); err != nil {
-		return fmt.Errorf("couldn't create node of type '%v': %w", name, err)
+	var err error
+	if d, ok := r.(rule.Drawer); ok {
+		err = d.PrepareGraphWithRand(sub, nidx, nidxs, bp, b.rnd)
+	} else {
+		err = r.PrepareGraph(sub, nidx, nidxs, bp)
+	}
+	if err != nil {
+		err = &blueprint.PositionError{Position: locate(blk.blueprint, blk.path),
+			Err: fmt.Errorf("couldn't create node of type '%v': %w", name, err)}
+		if errors.Is(err, rule.ErrInvalidGraph) {
+			b.report.fail(name, blk.path, err)
+			return &subtreeError{nidx, err}
+		}
+		return err
 	}
 
-	for name, children := range nidxs {
-		for i, child := range children {
-			if err := parse(g, child, namedChoices[name][i], resolver); err != nil {
-				return err
+	return b.buildSlots(sub, nidx, slots, next)
+}
+
+// variants enumerates the blueprints that result from choosing values for the variables of a block. Each variable
+// has up to b.samples values: small ranges contribute all their values, the others distinct ones that are drawn up
+// front. The combinations are tried in the order that the shuffle determines. A block without variables has one
+// variant, its blueprint, and draws nothing, unless its rule draws values itself. Then its blueprint is tried
+// b.samples times.
+func (b *builder) variants(blk *block) func() (*blueprint.Blueprint, bool) {
+	if len(blk.variables) == 0 {
+		n := 1
+		if blk.draws {
+			n = b.samples
+		}
+		return func() (*blueprint.Blueprint, bool) {
+			if n == 0 {
+				return nil, false
+			}
+			n--
+			return blk.blueprint, true
+		}
+	}
+
+	ns := make([]int, len(blk.variables))
+	samples := make([][]blueprint.Value, len(blk.variables))
+	for i, v := range blk.variables {
+		samples[i] = b.sample(v.distribution)
+		ns[i] = len(samples[i])
+	}
+
+	order := b.shuffle(ns, nil, b.rnd)
+	return func() (*blueprint.Blueprint, bool) {
+		is, ok := order()
+		if !ok {
+			return nil, false
+		}
+
+		bp := blk.blueprint
+		values := map[string][]blueprint.Value{}
+		for i, v := range blk.variables {
+			if values[v.property] == nil {
+				values[v.property] = append([]blueprint.Value{}, blk.blueprint.TypedValues(v.property)...)
+			}
+			values[v.property][v.index] = samples[i][is[i]]
+			if i == len(blk.variables)-1 || blk.variables[i+1].property != v.property {
+				bp = bp.With(v.property, values[v.property])
 			}
 		}
+		return bp, true
+	}
+}
+
+// sample returns the values of a distribution that are tried.
+func (b *builder) sample(d blueprint.Distribution) []blueprint.Value {
+	var values []blueprint.Value
+	if n := d.Count(); n > 0 && n <= b.samples {
+		for i := 0; i < n; i++ {
+			values = append(values, d.At(i))
+		}
+	} else if n > 0 {
+		drawn := map[int]bool{}
+		for len(values) < b.samples {
+			if i := b.rnd.Intn(n); !drawn[i] {
+				drawn[i] = true
+				values = append(values, d.At(i))
+			}
+		}
+	} else {
+		for len(values) < b.samples {
+			values = append(values, d.Sample(b.rnd))
+		}
+	}
+	return values
+}
+
+// A slot is a child node that still has to be built from one of its alternatives.
+type slot struct {
+	nidx         graph.NodeIndex
+	alternatives []*block
+	weights      []float64
+}
+
+// buildSlots builds the children of parent one after another.
+func (b *builder) buildSlots(
+	g *graph.Graph, parent graph.NodeIndex, slots []slot, next func(g *graph.Graph) error,
+) error {
+	if len(slots) == 0 {
+		return next(g)
+	}
+
+	s := slots[0]
+	reached := false
+	var last error = &subtreeError{s.nidx, fmt.Errorf("%w: no alternatives", rule.ErrInvalidGraph)}
+
+	order := b.shuffle([]int{len(s.alternatives)}, [][]float64{s.weights}, b.rnd)
+	for is, ok := order(); ok; is, ok = order() {
+		err := b.build(g, s.nidx, s.alternatives[is[0]], func(g *graph.Graph) error {
+			reached = true
+			return b.buildSlots(g, parent, slots[1:], next)
+		})
+
+		var se *subtreeError
+		if err == nil {
+			return nil
+		} else if errors.As(err, &se) && se.nidx == s.nidx || errors.Is(err, errRejected) {
+			last = err
+		} else {
+			return err
+		}
+	}
+
+	if reached {
+		// at least one alternative worked, the failure lies elsewhere
+		return errRejected
+	} else {
+		return &subtreeError{parent, last}
+	}
+}
+
+func isRetryable(err error) bool {
+	var se *subtreeError
+	return errors.As(err, &se) || errors.Is(err, errRejected)
+}
+
+// nodeState describes the parts of a node that were determined by its ancestors.
+func nodeState(g *graph.Graph, nidx graph.NodeIndex) string {
+	node := g.Node(nidx)
+	edges := make([]graph.Properties, len(node.Edges))
+	for i, eidx := range node.Edges {
+		edges[i] = g.Edge(eidx).Properties
+	}
+	return fmt.Sprint(node.Properties, edges)
+}
+
+// snapshot saves the properties of a node and its edges, which may be altered while its subtree is built. The
+// returned function restores them.
+func snapshot(g *graph.Graph, nidx graph.NodeIndex) func() {
+	node := g.Node(nidx)
+	nodeProps := copyProperties(node.Properties)
+	edgeProps := make([]graph.Properties, len(node.Edges))
+	for i, eidx := range node.Edges {
+		edgeProps[i] = copyProperties(g.Edge(eidx).Properties)
+	}
+
+	return func() {
+		node.Properties = nodeProps
+		for i, eidx := range node.Edges[:len(edgeProps)] {
+			g.Edge(eidx).Properties = edgeProps[i]
+		}
+	}
+}
+
+func copyProperties(props graph.Properties) graph.Properties {
+	out := make(graph.Properties, len(props))
+	for k, v := range props {
+		out[k] = v
 	}
-	return nil
+	return out
 }
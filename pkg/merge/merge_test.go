@@ -1,9 +1,14 @@
 package merge_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/nilsbu/arch/pkg/blueprint"
 	"github.com/nilsbu/arch/pkg/csp"
@@ -16,7 +21,7 @@ import (
 
 type checker func([]*graph.Graph) (bool, []graph.NodeIndex, error)
 
-func (fc checker) Match(graphs []*graph.Graph) (bool, []graph.NodeIndex, error) {
+func (fc checker) Match(ctx context.Context, graphs []*graph.Graph) (bool, []graph.NodeIndex, error) {
 	return fc(graphs)
 }
 
@@ -45,8 +50,9 @@ func with(r *merge.Resolver, add map[string]rule.Rule) *merge.Resolver {
 	return out
 }
 
+var allOk = checker(func([]*graph.Graph) (bool, []graph.NodeIndex, error) { return true, nil, nil })
+
 func TestBuild(t *testing.T) {
-	allOk := checker(func([]*graph.Graph) (bool, []graph.NodeIndex, error) { return true, nil, nil })
 
 	resolver := &merge.Resolver{
 		Name: "@",
@@ -202,14 +208,14 @@ func TestBuild(t *testing.T) {
 			nil,
 		},
 		{
-			"check with centipede",
+			"check with matcher",
 			[]string{
 				`{"@":"1","a":"Root","Root":[
 					{"@":"1","a":[{"@":"R"},{"@":"R"},{"@":"R"}]},
 					{"@":"1","a":{"@":"P"}}]}`,
 				`{"@":"1","a":{"@":"P"}}`,
 			},
-			&csp.Centipede{},
+			&csp.Matcher{},
 			resolver,
 			func() *graph.Graph {
 				g := graph.New(nil)
@@ -221,6 +227,9 @@ func TestBuild(t *testing.T) {
 				nidx, _ = g.Add(nidx)
 				node = g.Node(nidx)
 				node.Properties["name"] = "P"
+				node.Properties["requirements"] = []merge.Requirement{
+					{Constraint: 0, Node: graph.NodeIndex{1, 0}, Name: "P"},
+				}
 				return g
 			},
 			nil,
@@ -244,7 +253,7 @@ func TestBuild(t *testing.T) {
 				`{"@":"1","a":"Root","Root":[
 					{"@":"1","a":[{"@":"R"},{"@":"P"}]}]}`,
 			},
-			&csp.Centipede{},
+			&csp.Matcher{},
 			resolver,
 			func() *graph.Graph {
 				g := graph.New(nil)
@@ -337,8 +346,9 @@ func TestBuild(t *testing.T) {
 							g *graph.Graph, nidx graph.NodeIndex,
 							children map[string][]graph.NodeIndex, bp *blueprint.Blueprint) error {
 
+							// the first call is for the root, the second for the first option of its child
 							count++
-							if count == 1 {
+							if count == 2 {
 								return fmt.Errorf("%w", rule.ErrInvalidGraph)
 							} else {
 								return nil
@@ -457,14 +467,711 @@ func TestBuild(t *testing.T) {
 				}
 			}
 
-			if graph, err := merge.Build(bps, c.check, c.resolver, merge.InOrder); err != nil && c.err == nil {
+			res, err := merge.Build(context.Background(), bps, c.check, c.resolver, merge.InOrder, nil)
+			if err != nil && c.err == nil {
 				t.Errorf("unexpected error: %v", err)
 			} else if err == nil && c.err != nil {
 				t.Errorf("expected error but non ocurred")
 			} else if !errors.Is(err, c.err) {
 				t.Errorf("wrong type of error\nexpect: %v\nactual: %v", c.err, err)
-			} else if eq, ex := tg.AreEqual(c.graph(), graph); !eq {
-				t.Error("graph is wrong:", ex)
+			} else if err == nil {
+				if eq, ex := tg.AreEqual(c.graph(), res.Architecture); !eq {
+					t.Error("graph is wrong:", ex)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildIsReproducible(t *testing.T) {
+	resolver := &merge.Resolver{
+		Name: "@",
+		Keys: map[string]rule.Rule{
+			"1": &tr.RuleMock{Params: []string{"a", "b"}},
+			"R": &tr.RuleMock{},
+			"P": &tr.RuleMock{},
+		},
+	}
+
+	bp, err := blueprint.Parse([]byte(`{"@":"1","a":"X","b":["X","X"],
+		"X":[{"@":"R"},{"@":"P"},{"@":"1","a":"R","b":"P"}],
+		"R":{"@":"R"},"P":{"@":"P"}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	build := func() *graph.Graph {
+		res, err := merge.Build(context.Background(), []*blueprint.Blueprint{bp}, &csp.Matcher{}, resolver,
+			merge.RandomOrder, rand.New(rand.NewSource(1234)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return res.Architecture
+	}
+
+	if eq, ex := tg.AreEqual(build(), build()); !eq {
+		t.Error("graphs built with the same seed differ:", ex)
+	}
+}
+
+func TestBuildPrunesFailingSubtrees(t *testing.T) {
+	calls := map[string]int{}
+	counting := func(name string, fail bool) *tr.RuleMock {
+		return &tr.RuleMock{Prep: func(
+			g *graph.Graph, nidx graph.NodeIndex,
+			children map[string][]graph.NodeIndex, bp *blueprint.Blueprint) error {
+			calls[name]++
+			if fail {
+				return rule.ErrInvalidGraph
+			}
+			return nil
+		}}
+	}
+
+	resolver := &merge.Resolver{
+		Name: "@",
+		Keys: map[string]rule.Rule{
+			"1": &tr.RuleMock{Params: []string{"a", "b"}},
+			"R": counting("R", false),
+			"P": counting("P", false),
+			"X": counting("X", true),
+		},
+	}
+
+	// "b" can never be built. Neither the options for "a" nor the identical second "X" must be tried.
+	bp, err := blueprint.Parse([]byte(`{"@":"1","a":"A","A":[{"@":"R"},{"@":"P"}],"b":["X","X"],"X":{"@":"X"}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = merge.Build(context.Background(), []*blueprint.Blueprint{bp}, allOk, resolver, merge.InOrder, nil)
+	if !errors.Is(err, merge.ErrNoSolution) {
+		t.Fatalf("expected ErrNoSolution but got %v", err)
+	}
+
+	expect := map[string]int{"R": 1, "X": 1}
+	if !reflect.DeepEqual(expect, calls) {
+		t.Errorf("wrong number of calls:\nexpect: %v\nactual: %v", expect, calls)
+	}
+}
+
+func TestBuildHonoursWeights(t *testing.T) {
+	resolver := &merge.Resolver{
+		Name: "@",
+		Keys: map[string]rule.Rule{
+			"1": &tr.RuleMock{Params: []string{"a"}},
+			"R": &tr.RuleMock{},
+			"P": &tr.RuleMock{},
+			"Q": &tr.RuleMock{},
+		},
+	}
+
+	// P and Q share the weight of "B"
+	bp, err := blueprint.Parse([]byte(`{"@":"1","a":"A","A":["R*6","B*2"],"B":["P*3","Q"],
+		"R":{"@":"R"},"P":{"@":"P"},"Q":{"@":"Q"}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	counts := map[string]int{}
+	rnd := rand.New(rand.NewSource(99))
+	for i := 0; i < 1000; i++ {
+		res, err := merge.Build(
+			context.Background(), []*blueprint.Blueprint{bp}, allOk, resolver, merge.RandomOrder, rnd)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		counts[res.Architecture.Node(graph.NodeIndex{1, 0}).Properties["name"].(string)]++
+	}
+
+	for name, expect := range map[string]int{"R": 750, "P": 188, "Q": 62} {
+		if counts[name] < expect*8/10 || counts[name] > expect*12/10 {
+			t.Errorf("expected %v to be chosen about %v times but it was %v times", name, expect, counts[name])
+		}
+	}
+}
+
+func TestBuildBudget(t *testing.T) {
+	resolver := &merge.Resolver{
+		Name: "@",
+		Keys: map[string]rule.Rule{
+			"1": &tr.RuleMock{Params: []string{"a", "a"}},
+			"R": &tr.RuleMock{},
+		},
+	}
+	bp, _ := blueprint.Parse([]byte(`{"@":"1","a":"A","A":["R","R","R","R"],"R":{"@":"R"}}`))
+	noneOk := checker(func([]*graph.Graph) (bool, []graph.NodeIndex, error) { return false, nil, nil })
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, c := range []struct {
+		name       string
+		ctx        context.Context
+		check      merge.Check
+		opts       []merge.Option
+		cause      error
+		candidates int
+	}{
+		{
+			"no limit exhausts all candidates",
+			context.Background(), noneOk, nil,
+			merge.ErrNoSolution, -1,
+		},
+		{
+			"limit of candidates",
+			context.Background(), noneOk, []merge.Option{merge.MaxCandidates(3)},
+			merge.ErrBudgetExceeded, 3,
+		},
+		{
+			"limit larger than number of candidates",
+			context.Background(), noneOk, []merge.Option{merge.MaxCandidates(100)},
+			merge.ErrNoSolution, -1,
+		},
+		{
+			"context canceled",
+			canceled, noneOk, nil,
+			context.Canceled, 0,
+		},
+		{
+			"timeout while matching",
+			context.Background(),
+			checkerCtx(func(ctx context.Context) (bool, []graph.NodeIndex, error) {
+				<-ctx.Done()
+				return false, nil, ctx.Err()
+			}),
+			[]merge.Option{merge.Timeout(10 * time.Millisecond)},
+			context.DeadlineExceeded, 1,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := merge.Build(c.ctx, []*blueprint.Blueprint{bp}, c.check, resolver, merge.InOrder, nil, c.opts...)
+			if !errors.Is(err, c.cause) {
+				t.Fatalf("expected %v but got %v", c.cause, err)
+			}
+
+			var be *merge.BudgetError
+			if c.candidates < 0 {
+				if errors.As(err, &be) {
+					t.Errorf("didn't expect BudgetError but got %v", err)
+				}
+			} else if !errors.As(err, &be) || !errors.Is(err, merge.ErrBudgetExceeded) {
+				t.Errorf("expected BudgetError but got %v", err)
+			} else if be.Candidates != c.candidates {
+				t.Errorf("expected %v candidates but got %v", c.candidates, be.Candidates)
+			}
+		})
+	}
+}
+
+type checkerCtx func(ctx context.Context) (bool, []graph.NodeIndex, error)
+
+func (fc checkerCtx) Match(ctx context.Context, graphs []*graph.Graph) (bool, []graph.NodeIndex, error) {
+	return fc(ctx)
+}
+
+func TestBuildReport(t *testing.T) {
+	errFurniture := errors.New("furniture doesn't fit")
+	resolver := &merge.Resolver{
+		Name: "@",
+		Keys: map[string]rule.Rule{
+			"1": &tr.RuleMock{Params: []string{"a", "b"}},
+			"R": &tr.RuleMock{},
+			"X": &tr.RuleMock{Prep: func(
+				g *graph.Graph, nidx graph.NodeIndex,
+				children map[string][]graph.NodeIndex, bp *blueprint.Blueprint) error {
+				return fmt.Errorf("%w: %v", rule.ErrInvalidGraph, errFurniture)
+			}},
+		},
+	}
+
+	bp, _ := blueprint.Parse([]byte(`{"@":"1","a":"A","b":"B",
+		"A":[{"@":"X"},{"@":"R"},{"@":"R"}],
+		"B":["Inner", {"@":"R"}],
+		"Inner":{"@":"1","a":{"@":"X"},"b":{"@":"X"}}}`))
+	noneOk := checker(func([]*graph.Graph) (bool, []graph.NodeIndex, error) { return false, nil, nil })
+
+	_, err := merge.Build(context.Background(), []*blueprint.Blueprint{bp}, noneOk, resolver, merge.InOrder, nil)
+
+	var nse *merge.NoSolutionError
+	if !errors.Is(err, merge.ErrNoSolution) || !errors.As(err, &nse) {
+		t.Fatalf("expected NoSolutionError but got %v", err)
+	}
+
+	report := nse.Report
+	if report.Candidates != 2 || report.Rejected != 2 {
+		t.Errorf("expected 2 candidates and 2 rejections but got %v and %v", report.Candidates, report.Rejected)
+	}
+	// "Inner" fails for the first option of "a" that works, for the second one the failure is remembered
+	if report.Failures() != 2 || report.Skipped != 1 {
+		t.Errorf("expected 2 failures and 1 skipped attempt but got %v and %v", report.Failures(), report.Skipped)
+	}
+	if tally := report.Rules["X"]; tally == nil || tally.Count != 2 || !errors.Is(tally.Sample, rule.ErrInvalidGraph) {
+		t.Errorf("wrong tally for rule X: %v", tally)
+	}
+	if tally := report.Kinds[rule.ErrInvalidGraph.Error()]; tally == nil || tally.Count != 2 {
+		t.Errorf("wrong tally for type of error: %v", report.Kinds)
+	}
+	expectPaths := map[string]int{"a/A": 1, "b/B/Inner/a": 1}
+	if len(report.Paths) != len(expectPaths) {
+		t.Errorf("wrong paths: %v", report.Paths)
+	}
+	for path, n := range expectPaths {
+		if tally := report.Paths[path]; tally == nil || tally.Count != n {
+			t.Errorf("expected %v failures for path '%v' but got %v", n, path, tally)
+		}
+	}
+
+	if s := report.String(); !strings.Contains(s, "b/B/Inner/a") || !strings.Contains(s, "furniture doesn't fit") {
+		t.Errorf("summary lacks information:\n%v", s)
+	}
+}
+
+func TestBuildReportKinds(t *testing.T) {
+	failWith := func(err error) *tr.RuleMock {
+		return &tr.RuleMock{Prep: func(
+			g *graph.Graph, nidx graph.NodeIndex,
+			children map[string][]graph.NodeIndex, bp *blueprint.Blueprint) error {
+			return fmt.Errorf("%w: details", err)
+		}}
+	}
+	resolver := &merge.Resolver{
+		Name: "@",
+		Keys: map[string]rule.Rule{
+			"1": &tr.RuleMock{Params: []string{"a", "b"}},
+			"R": &tr.RuleMock{},
+			"S": failWith(rule.ErrTooSmall),
+			"W": failWith(rule.ErrNoSharedWall),
+		},
+	}
+
+	bp, _ := blueprint.Parse([]byte(`{"@":"1","a":"A","b":"B","A":[{"@":"S"},{"@":"R"}],"B":[{"@":"W"},{"@":"R"}]}`))
+	noneOk := checker(func([]*graph.Graph) (bool, []graph.NodeIndex, error) { return false, nil, nil })
+
+	_, err := merge.Build(context.Background(), []*blueprint.Blueprint{bp}, noneOk, resolver, merge.InOrder, nil)
+
+	var nse *merge.NoSolutionError
+	if !errors.As(err, &nse) {
+		t.Fatalf("expected NoSolutionError but got %v", err)
+	}
+	for _, k := range []error{rule.ErrTooSmall, rule.ErrNoSharedWall} {
+		if tally := nse.Report.Kinds[k.Error()]; tally == nil || tally.Count != 1 || !errors.Is(tally.Sample, k) {
+			t.Errorf("wrong tally for '%v': %v", k, tally)
+		}
+	}
+	if len(nse.Report.Kinds) != 2 {
+		t.Errorf("expected 2 kinds but got %v", nse.Report.Kinds)
+	}
+}
+
+func TestBuildResult(t *testing.T) {
+	resolver := &merge.Resolver{
+		Name: "@",
+		Keys: map[string]rule.Rule{
+			"1": &tr.RuleMock{Params: []string{"a"}},
+			"R": &tr.RuleMock{},
+			"P": &tr.RuleMock{},
+		},
+	}
+
+	parse := func(jsons ...string) []*blueprint.Blueprint {
+		bps := make([]*blueprint.Blueprint, len(jsons))
+		for i, j := range jsons {
+			bps[i], _ = blueprint.Parse([]byte(j))
+		}
+		return bps
+	}
+
+	t.Run("matches are returned and annotated", func(t *testing.T) {
+		bps := parse(`{"@":"1","a":[{"@":"R"},{"@":"P"}]}`, `{"@":"1","a":[{"@":"P"},{"@":"R"}]}`, `{"@":"1"}`)
+		res, err := merge.Build(context.Background(), bps, &csp.Matcher{}, resolver, merge.InOrder, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(res.Constraints) != 2 {
+			t.Fatalf("expected 2 constraint graphs but got %v", len(res.Constraints))
+		}
+		expect := []map[graph.NodeIndex]graph.NodeIndex{
+			{{1, 0}: {1, 1}, {1, 1}: {1, 0}},
+			{},
+		}
+		if !reflect.DeepEqual(expect, res.Matches) {
+			t.Errorf("wrong matches:\nexpect: %v\nactual: %v", expect, res.Matches)
+		}
+
+		reqs := res.Architecture.Node(graph.NodeIndex{1, 1}).Properties["requirements"]
+		if !reflect.DeepEqual([]merge.Requirement{{Constraint: 0, Node: graph.NodeIndex{1, 0}, Name: "P"}}, reqs) {
+			t.Errorf("wrong requirements: %v", reqs)
+		}
+	})
+
+	t.Run("no matches from check", func(t *testing.T) {
+		bps := parse(`{"@":"1","a":{"@":"R"}}`, `{"@":"1","a":{"@":"R"}}`)
+		res, err := merge.Build(context.Background(), bps, allOk, resolver, merge.InOrder, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual([]map[graph.NodeIndex]graph.NodeIndex{{}}, res.Matches) {
+			t.Errorf("expected empty matches but got %v", res.Matches)
+		}
+	})
+
+	t.Run("wrong number of matches", func(t *testing.T) {
+		bps := parse(`{"@":"1","a":{"@":"R"}}`, `{"@":"1","a":{"@":"R"}}`)
+		tooMany := checker(func([]*graph.Graph) (bool, []graph.NodeIndex, error) {
+			return true, []graph.NodeIndex{{1, 0}, {1, 0}}, nil
+		})
+		_, err := merge.Build(context.Background(), bps, tooMany, resolver, merge.InOrder, nil)
+		if !errors.Is(err, merge.ErrInvalidMatches) {
+			t.Errorf("expected ErrInvalidMatches but got %v", err)
+		}
+	})
+}
+
+func TestBuildOptimize(t *testing.T) {
+	resolver := &merge.Resolver{
+		Name: "@",
+		Keys: map[string]rule.Rule{
+			"1": &tr.RuleMock{Params: []string{"a"}},
+			"R": &tr.RuleMock{},
+			"P": &tr.RuleMock{},
+		},
+	}
+	arch, _ := blueprint.Parse([]byte(`{"@":"1","a":["A","A"],"A":["R","P"],"R":{"@":"R"},"P":{"@":"P"}}`))
+	constraint, _ := blueprint.Parse([]byte(`{"@":"1","a":{"@":"R"}}`))
+	bps := []*blueprint.Blueprint{arch, constraint}
+
+	// every P counts 10, matching the R onto the second node counts 1
+	score := func(graphs []*graph.Graph, matches []graph.NodeIndex) float64 {
+		value := float64(matches[0][1])
+		for _, nidx := range graphs[0].Children(graph.NodeIndex{}) {
+			if graphs[0].Node(nidx).Properties["name"] == "P" {
+				value += 10
+			}
+		}
+		return value
+	}
+	names := func(res *merge.Result) []string {
+		var names []string
+		for _, nidx := range res.Architecture.Children(graph.NodeIndex{}) {
+			names = append(names, res.Architecture.Node(nidx).Properties["name"].(string))
+		}
+		return names
+	}
+
+	for _, c := range []struct {
+		name    string
+		n       int
+		names   []string
+		matches map[graph.NodeIndex]graph.NodeIndex
+		score   float64
+	}{
+		{"all architectures", 0, []string{"P", "R"}, map[graph.NodeIndex]graph.NodeIndex{{1, 0}: {1, 1}}, 11},
+		{"first architecture only", 1, []string{"R", "R"}, map[graph.NodeIndex]graph.NodeIndex{{1, 0}: {1, 1}}, 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := merge.Build(context.Background(), bps, &csp.Matcher{}, resolver, merge.InOrder, nil,
+				merge.Optimize(score, c.n))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(c.names, names(res)) {
+				t.Errorf("wrong architecture:\nexpect: %v\nactual: %v", c.names, names(res))
+			}
+			if !reflect.DeepEqual(c.matches, res.Matches[0]) {
+				t.Errorf("wrong matches:\nexpect: %v\nactual: %v", c.matches, res.Matches[0])
+			}
+			if c.score != res.Score {
+				t.Errorf("expected score %v but got %v", c.score, res.Score)
+			}
+		})
+	}
+
+	t.Run("check must be an enumerator", func(t *testing.T) {
+		_, err := merge.Build(context.Background(), bps, allOk, resolver, merge.InOrder, nil, merge.Optimize(score, 0))
+		if !errors.Is(err, merge.ErrCannotEnumerate) {
+			t.Errorf("expected ErrCannotEnumerate but got %v", err)
+		}
+	})
+
+	t.Run("no architecture fits", func(t *testing.T) {
+		none, _ := blueprint.Parse([]byte(`{"@":"1","a":{"@":"Q"}}`))
+		resolver := with(resolver, map[string]rule.Rule{"Q": &tr.RuleMock{}})
+		_, err := merge.Build(context.Background(), []*blueprint.Blueprint{arch, none}, &csp.Matcher{}, resolver,
+			merge.InOrder, nil, merge.Optimize(score, 0))
+		if !errors.Is(err, merge.ErrNoSolution) {
+			t.Errorf("expected ErrNoSolution but got %v", err)
+		}
+	})
+}
+
+func TestBuildErrorPositions(t *testing.T) {
+	resolver := &merge.Resolver{
+		Name: "@",
+		Keys: map[string]rule.Rule{
+			"1": &tr.RuleMock{Params: []string{"a"}},
+			"F": &tr.RuleMock{Prep: func(
+				*graph.Graph, graph.NodeIndex, map[string][]graph.NodeIndex, *blueprint.Blueprint,
+			) error {
+				return rule.ErrPreparation
+			}},
+		},
+	}
+
+	for _, c := range []struct {
+		name string
+		json string
+		err  error
+		pos  blueprint.Position
+	}{
+		{"ambiguous rule", "{\"@\":\"1\",\n\"a\":\"X\",\n\"X\":{\"@\":[\"1\",\"1\"]}}", merge.ErrInvalidBlueprint,
+			blueprint.Position{Line: 3, Path: "a/X"}},
+		{"undefined rule", "{\"@\":\"1\",\n\"a\":\"X\",\n\"X\":{\n\"@\":\"Y\"}}", merge.ErrInvalidBlueprint,
+			blueprint.Position{Line: 4, Path: "a/X"}},
+		{"failing rule", "{\"@\":\"1\",\n\"a\":{\"@\":\"F\"}}", rule.ErrPreparation,
+			blueprint.Position{Line: 2, Path: "a"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bp, err := blueprint.Parse([]byte(c.json))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, err = merge.Build(context.Background(), []*blueprint.Blueprint{bp}, allOk, resolver, merge.InOrder, nil)
+			var pe *blueprint.PositionError
+			if !errors.Is(err, c.err) {
+				t.Errorf("expected %v but got %v", c.err, err)
+			} else if !errors.As(err, &pe) {
+				t.Errorf("expected PositionError but got %v", err)
+			} else if c.pos != pe.Position {
+				t.Errorf("expected position %v but got %v", c.pos, pe.Position)
+			}
+		})
+	}
+}
+
+// declaring is a rule that declares its value parameters.
+type declaring struct {
+	*tr.RuleMock
+	params []rule.Param
+}
+
+func (d declaring) ValueParams() []rule.Param {
+	return d.params
+}
+
+func TestBuildDistributions(t *testing.T) {
+	// the rule only accepts some values, so Build has to try several
+	accept := func(property string, ok func(float64) bool) *tr.RuleMock {
+		return &tr.RuleMock{Prep: func(
+			g *graph.Graph, nidx graph.NodeIndex, children map[string][]graph.NodeIndex, bp *blueprint.Blueprint,
+		) error {
+			if f, err := bp.Float(property); err != nil {
+				return err
+			} else if !ok(f) {
+				return fmt.Errorf("%w: %v", rule.ErrInvalidGraph, f)
+			} else {
+				g.Node(nidx).Properties[property] = f
+				return nil
+			}
+		}}
+	}
+
+	resolver := &merge.Resolver{
+		Name: "@",
+		Keys: map[string]rule.Rule{
+			"1":     &tr.RuleMock{Params: []string{"a"}},
+			"Four":  accept("w", func(f float64) bool { return f == 4 }),
+			"Large": accept("r", func(f float64) bool { return f >= .5 }),
+			"Declared": declaring{accept("w", func(f float64) bool { return f == 4 }),
+				[]rule.Param{{Name: "w", Type: rule.IntValue}}},
+		},
+	}
+
+	for _, c := range []struct {
+		name  string
+		json  string
+		opts  []merge.Option
+		ok    bool
+		value interface{}
+	}{
+		{
+			"all values of a small range are tried",
+			`{"@":"1","a":{"@":"Four","w":"range(1,5)"}}`,
+			[]merge.Option{merge.Samples(5)},
+			true, 4.,
+		},
+		{
+			"range without the accepted value",
+			`{"@":"1","a":{"@":"Four","w":"range(5,9)"}}`,
+			[]merge.Option{merge.Samples(10)},
+			false, nil,
+		},
+		{
+			"inherited range of declared parameter",
+			`{"@":"1","w":"range(3,4)","a":{"@":"Declared"}}`,
+			nil,
+			true, 4.,
+		},
+		{
+			"inherited range of undeclared parameter isn't drawn",
+			`{"@":"1","w":"range(4,4)","a":{"@":"Four"}}`,
+			nil,
+			false, nil,
+		},
+		{
+			"uniform distribution is sampled",
+			`{"@":"1","a":{"@":"Large","r":"uniform(0.4,1)"}}`,
+			[]merge.Option{merge.Samples(20)},
+			true, nil,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bp, err := blueprint.Parse([]byte(c.json))
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			res, err := merge.Build(context.Background(), []*blueprint.Blueprint{bp}, allOk, resolver,
+				merge.RandomOrder, rand.New(rand.NewSource(1)), c.opts...)
+			if !c.ok {
+				if err == nil {
+					t.Fatal("expected error but none occurred")
+				}
+				return
+			} else if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			g := res.Architecture
+			props := g.Node(g.Children(graph.NodeIndex{})[0]).Properties
+			if c.value != nil && props["w"] != c.value {
+				t.Errorf("expected %v but got %v", c.value, props["w"])
+			} else if r, ok := props["r"].(float64); c.value == nil && (!ok || r < .5 || r >= 1) {
+				t.Errorf("sampled value %v is out of range", props["r"])
+			}
+		})
+	}
+}
+
+// drawing is a rule that draws "r" itself and only accepts draws of at least .5.
+type drawing struct {
+	calls *int
+}
+
+func (d drawing) ChildParams() []string {
+	return nil
+}
+
+func (d drawing) ValueParams() []rule.Param {
+	return []rule.Param{{Name: "r", Type: rule.FloatValue, Drawn: true}}
+}
+
+func (d drawing) PrepareGraph(g *graph.Graph, nidx graph.NodeIndex, children map[string][]graph.NodeIndex,
+	bp *blueprint.Blueprint) error {
+	return errors.New("PrepareGraph was called")
+}
+
+func (d drawing) PrepareGraphWithRand(g *graph.Graph, nidx graph.NodeIndex, children map[string][]graph.NodeIndex,
+	bp *blueprint.Blueprint, rnd *rand.Rand) error {
+	*d.calls++
+	if dist, ok := bp.TypedValues("r")[0].Distribution(); !ok {
+		return errors.New("distribution was drawn by Build")
+	} else if r, _ := dist.Sample(rnd).Float(); r < .5 {
+		return fmt.Errorf("%w: %v", rule.ErrInvalidGraph, r)
+	} else {
+		g.Node(nidx).Properties["r"] = r
+		return nil
+	}
+}
+
+func TestBuildDrawer(t *testing.T) {
+	calls := 0
+	resolver := &merge.Resolver{Name: "@", Keys: map[string]rule.Rule{"D": drawing{&calls}}}
+	bp, _ := blueprint.Parse([]byte(`{"@":"D","r":"uniform(0,1)"}`))
+
+	// with seed 6, the first draw is below .5 and the second one isn't
+	res, err := merge.Build(context.Background(), []*blueprint.Blueprint{bp}, allOk, resolver,
+		merge.InOrder, rand.New(rand.NewSource(6)))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	} else if calls != 2 {
+		t.Errorf("expected 2 attempts but got %v", calls)
+	} else if r := res.Architecture.Node(graph.NodeIndex{}).Properties["r"]; r == nil {
+		t.Error("node wasn't prepared")
+	}
+}
+
+func TestBuildRecursion(t *testing.T) {
+	resolver := &merge.Resolver{
+		Name: "@",
+		Keys: map[string]rule.Rule{
+			"C": &tr.RuleMock{Params: []string{"side"}},
+			"L": &tr.RuleMock{Params: []string{"side"}},
+			"R": &tr.RuleMock{},
+		},
+		Depth:  "@depth",
+		Depths: map[string]int{"L": 1},
+	}
+	noneOk := checker(func([]*graph.Graph) (bool, []graph.NodeIndex, error) { return false, nil, nil })
+	wing := `{"@":"C","side":"Side","Side":["End","Wing"],"End":{"@":"R"},"Wing":{"@":"%v","side":"Side"%v}}`
+
+	for _, c := range []struct {
+		name string
+		json string
+		opts []merge.Option
+		// candidates is the number of trees that can be built, -1 means that Build fails before
+		candidates int
+		msg        string
+	}{
+		{"no recursion by default", fmt.Sprintf(wing, "C", ""), nil, 2, ""},
+		{"global depth", fmt.Sprintf(wing, "C", ""), []merge.Option{merge.MaxDepth(3)}, 5, ""},
+		{"own depth", fmt.Sprintf(wing, "C", `,"@depth":1`), []merge.Option{merge.MaxDepth(5)}, 3, ""},
+		{"rule depth", fmt.Sprintf(wing, "L", ""), []merge.Option{merge.MaxDepth(3)}, 3, ""},
+		{"rule depth is stricter than own", fmt.Sprintf(wing, "L", `,"@depth":3`), nil, 3, ""},
+		{"own depth is stricter than rule depth", fmt.Sprintf(wing, "L", `,"@depth":0`), nil, 2, ""},
+		{"global depth is stricter than rule depth", fmt.Sprintf(wing, "L", ""), nil, 2, ""},
+		{
+			"no end",
+			`{"@":"C","side":"Wing","Wing":{"@":"C","side":"Wing"}}`, []merge.Option{merge.MaxDepth(3)},
+			-1, "cannot be completed",
+		},
+		{
+			"reference cycle",
+			`{"@":"C","side":"A","A":["B","End"],"B":["A"],"End":{"@":"R"}}`, nil,
+			-1, "reference cycle A -> B -> A",
+		},
+		{
+			"inherited rule",
+			`{"@":"C","side":{"x":1}}`, []merge.Option{merge.MaxDepth(2)},
+			-1, "cannot be completed",
+		},
+		{
+			"negative depth",
+			fmt.Sprintf(wing, "C", `,"@depth":-1`), nil,
+			-1, "'@depth' must not be negative",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bp, err := blueprint.Parse([]byte(c.json))
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			_, err = merge.Build(context.Background(), []*blueprint.Blueprint{bp}, noneOk, resolver,
+				merge.InOrder, rand.New(rand.NewSource(0)), c.opts...)
+			var nse *merge.NoSolutionError
+			if c.candidates < 0 {
+				if !errors.Is(err, merge.ErrInvalidBlueprint) {
+					t.Fatalf("expected ErrInvalidBlueprint but got %v", err)
+				} else if !strings.Contains(err.Error(), c.msg) {
+					t.Errorf("expected '%v' in '%v'", c.msg, err)
+				}
+			} else if !errors.As(err, &nse) {
+				t.Fatalf("expected *NoSolutionError but got %v", err)
+			} else if nse.Report.Candidates != c.candidates {
+				t.Errorf("expected %v candidates but got %v", c.candidates, nse.Report.Candidates)
 			}
 		})
 	}
@@ -0,0 +1,50 @@
+package merge
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nilsbu/arch/pkg/graph"
+)
+
+// errComparedEnough stops the search once the number of architectures set by Optimize has been compared.
+var errComparedEnough = errors.New("compared enough architectures")
+
+// optimizer keeps the best architecture that Build encountered so far.
+type optimizer struct {
+	enumerator Enumerator
+	score      Score
+	compare    int
+	maxMatches int
+
+	compared int
+	best     *Result
+}
+
+// consider rates the graphs by their best matches. ok is false if there are none. The graphs are copied when they are
+// better than the best ones so far, since the search will continue to modify them.
+func (o *optimizer) consider(ctx context.Context, gs []*graph.Graph) (ok bool, err error) {
+	ok, matches, value, err := Best(ctx, o.enumerator, gs, o.score, o.maxMatches)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	o.compared++
+	if o.best == nil || value > o.best.Score {
+		copies := make([]*graph.Graph, len(gs))
+		for i, g := range gs {
+			copies[i] = g.Copy()
+		}
+		if res, err := newResult(copies, matches); err != nil {
+			return false, err
+		} else {
+			res.Score = value
+			o.best = res
+		}
+	}
+	return true, nil
+}
+
+func (o *optimizer) done() bool {
+	return o.compare > 0 && o.compared >= o.compare
+}
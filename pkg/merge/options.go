@@ -0,0 +1,107 @@
+package merge
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBudgetExceeded is returned when Build stops before the search is complete. This happens when the context is
+// done or one of the limits set through Options is reached.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// errTooManyCandidates is the cause of a BudgetError when the limit set by MaxCandidates is reached.
+var errTooManyCandidates = errors.New("maximum number of candidates reached")
+
+// A BudgetError is returned when Build was stopped early.
+// It matches ErrBudgetExceeded and wraps the cause, which may e.g. be context.DeadlineExceeded.
+type BudgetError struct {
+	// Candidates is the number of complete sets of graphs that were passed to the Check.
+	Candidates int
+	Cause      error
+	// Report summarizes the failures up to the point where Build was stopped.
+	Report *Report
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("%v after %v candidates: %v", ErrBudgetExceeded, e.Candidates, e.Cause)
+}
+
+func (e *BudgetError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+func (e *BudgetError) Unwrap() error {
+	return e.Cause
+}
+
+// An Option configures Build.
+type Option func(*options)
+
+type options struct {
+	maxCandidates int
+	timeout       time.Duration
+	score         Score
+	compare       int
+	maxMatches    int
+	samples       int
+	maxDepth      int
+}
+
+// defaultSamples is the number of values that are tried for a distribution unless Samples says otherwise.
+const defaultSamples = 3
+
+// MaxCandidates limits the number of complete sets of graphs that are passed to the Check.
+// Values less than 1 remove the limit.
+func MaxCandidates(n int) Option {
+	return func(o *options) {
+		o.maxCandidates = n
+	}
+}
+
+// Timeout limits the time that Build may take.
+// Values less than or equal to zero remove the limit.
+func Timeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// Optimize makes Build compare architectures instead of returning the first one that passes the Check. Each of them is
+// rated by the score of its best matches and the one with the highest score is returned. Build stops after n
+// architectures were compared. Values less than 1 let it continue until all candidates were tried.
+// When Build is stopped early, e.g. by a Timeout, the best architecture found so far is returned without an error.
+// The Check must be an Enumerator.
+func Optimize(score Score, n int) Option {
+	return func(o *options) {
+		o.score = score
+		o.compare = n
+	}
+}
+
+// MaxMatches limits the number of solutions per architecture that are scored when optimizing.
+// Values less than 1 remove the limit.
+func MaxMatches(n int) Option {
+	return func(o *options) {
+		o.maxMatches = n
+	}
+}
+
+// Samples sets how many values are tried for each distribution in a blueprint, see blueprint.Distribution. Ranges with
+// at most n values are tried completely. Values are only tried when the previous ones failed, so a higher n makes
+// the search more thorough but slower. Values less than 1 keep the default of 3.
+func Samples(n int) Option {
+	return func(o *options) {
+		o.samples = n
+	}
+}
+
+// MaxDepth sets how often a blueprint may occur inside itself, e.g. a corridor that has another corridor at its side.
+// Alternatives that would exceed it are dropped, so recursion ends with the alternatives that don't recurse. The
+// default of 0 forbids recursion. Blueprints can set their own limit through Resolver.Depth and rules can be limited
+// through Resolver.Depths.
+func MaxDepth(n int) Option {
+	return func(o *options) {
+		o.maxDepth = n
+	}
+}
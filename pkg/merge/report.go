@@ -0,0 +1,152 @@
+package merge
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nilsbu/arch/pkg/area"
+	"github.com/nilsbu/arch/pkg/rule"
+)
+
+// A NoSolutionError is returned by Build when every candidate failed.
+// It matches ErrNoSolution and contains a Report on why the candidates failed.
+type NoSolutionError struct {
+	Report *Report
+}
+
+func (e *NoSolutionError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrNoSolution, e.Report.overview())
+}
+
+func (e *NoSolutionError) Is(target error) bool {
+	return target == ErrNoSolution
+}
+
+// A Report summarizes the failures that occurred while building.
+// Failures are attempts to prepare a node that failed with rule.ErrInvalidGraph. Each of them is counted once by the
+// name of the rule, once by the type of the error and once by the path in the blueprint that led to the node. The
+// type is the most specific of the rule's and area's errors that the failure matches, e.g. rule.ErrTooSmall.
+//
+// A path consists of the names of parameters and references that were followed from the root, separated by '/', e.g.
+// "interior/content/Interior/MainCorridor/left/Bedroom/furniture". The root itself has the path "".
+type Report struct {
+	// Candidates is the number of complete sets of graphs that were passed to the Check.
+	Candidates int
+	// Rejected is the number of candidates that the Check didn't accept.
+	Rejected int
+	// Skipped is the number of attempts that weren't made because the same attempt had failed before.
+	Skipped int
+
+	Rules map[string]*Tally
+	Kinds map[string]*Tally
+	Paths map[string]*Tally
+}
+
+// A Tally counts failures of one category and keeps the first error of that category as a sample.
+type Tally struct {
+	Count  int
+	Sample error
+}
+
+func newReport() *Report {
+	return &Report{
+		Rules: map[string]*Tally{},
+		Kinds: map[string]*Tally{},
+		Paths: map[string]*Tally{},
+	}
+}
+
+// Failures returns the total number of failures.
+func (r *Report) Failures() int {
+	n := 0
+	for _, t := range r.Rules {
+		n += t.Count
+	}
+	return n
+}
+
+func (r *Report) fail(rule, path string, err error) {
+	tally(r.Rules, rule, err)
+	tally(r.Kinds, kind(err), err)
+	tally(r.Paths, path, err)
+}
+
+func tally(tallies map[string]*Tally, key string, err error) {
+	if t, ok := tallies[key]; ok {
+		t.Count++
+	} else {
+		tallies[key] = &Tally{Count: 1, Sample: err}
+	}
+}
+
+// kinds are the errors by which failures are classified. More specific errors come first.
+var kinds = []error{
+	rule.ErrTooSmall,
+	rule.ErrNoRoomForDoors,
+	rule.ErrNoSharedWall,
+	rule.ErrUnassignableDoor,
+	area.ErrInvalidSplit,
+	area.ErrInvalidDoor,
+	area.ErrInvalidRotation,
+	rule.ErrInvalidGraph,
+}
+
+// kind identifies the type of an error by the first of kinds that it matches.
+func kind(err error) string {
+	for _, k := range kinds {
+		if errors.Is(err, k) {
+			return k.Error()
+		}
+	}
+	return err.Error()
+}
+
+func (r *Report) overview() string {
+	return fmt.Sprintf("%v candidates (%v rejected by check), %v failed attempts, %v skipped",
+		r.Candidates, r.Rejected, r.Failures(), r.Skipped)
+}
+
+// String returns a readable summary of the report. Categories are sorted by the number of failures.
+func (r *Report) String() string {
+	sb := &strings.Builder{}
+	sb.WriteString(r.overview())
+	for _, section := range []struct {
+		title   string
+		tallies map[string]*Tally
+	}{
+		{"rule", r.Rules},
+		{"type", r.Kinds},
+		{"blueprint path", r.Paths},
+	} {
+		if len(section.tallies) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(sb, "\nfailures by %v:", section.title)
+		for _, key := range sortedKeys(section.tallies) {
+			label := key
+			if label == "" {
+				label = "<root>"
+			}
+			t := section.tallies[key]
+			fmt.Fprintf(sb, "\n  %6d  %v\n          e.g. %v", t.Count, label, t.Sample)
+		}
+	}
+	return sb.String()
+}
+
+func sortedKeys(tallies map[string]*Tally) []string {
+	keys := make([]string, 0, len(tallies))
+	for key := range tallies {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if ci, cj := tallies[keys[i]].Count, tallies[keys[j]].Count; ci != cj {
+			return ci > cj
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
@@ -7,4 +7,10 @@ import (
 type Resolver struct {
 	Name string
 	Keys map[string]rule.Rule
+	// Depth is the property through which a blueprint sets how often it may occur inside itself, overriding MaxDepth.
+	// If it is empty, only MaxDepth applies.
+	Depth string
+	// Depths limits how often blueprints that use a rule may occur inside themselves, by the name of the rule. Where a
+	// rule and a blueprint or MaxDepth set limits, the stricter one applies.
+	Depths map[string]int
 }
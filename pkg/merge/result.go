@@ -0,0 +1,77 @@
+package merge
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nilsbu/arch/pkg/graph"
+)
+
+// ErrInvalidMatches is returned when a Check returns matches that don't correspond to the graphs it was given.
+var ErrInvalidMatches = errors.New("matches don't fit graphs")
+
+// A Result is the outcome of Build.
+type Result struct {
+	// Architecture is the graph that was built from the first blueprint.
+	Architecture *graph.Graph
+	// Constraints are the graphs that were built from the remaining blueprints.
+	Constraints []*graph.Graph
+	// Matches maps the nodes of each constraint graph to the nodes of the architecture they were matched onto.
+	// Matches[i] belongs to Constraints[i]. A map is empty when the Check didn't return matches.
+	Matches []map[graph.NodeIndex]graph.NodeIndex
+	// Score is the score of the matches if Build was optimizing and 0 otherwise.
+	Score float64
+}
+
+// A Requirement refers to a node in a constraint graph.
+// Nodes in the architecture that were matched store the requirements they fulfil as a []Requirement in the property
+// "requirements".
+type Requirement struct {
+	// Constraint is the index of the constraint graph in Result.Constraints.
+	Constraint int
+	// Node is the node in the constraint graph.
+	Node graph.NodeIndex
+	// Name is the name of the constraint node, if it has one.
+	Name string
+}
+
+func newResult(gs []*graph.Graph, matches []graph.NodeIndex) (*Result, error) {
+	res := &Result{
+		Architecture: gs[0],
+		Constraints:  append([]*graph.Graph{}, gs[1:]...),
+		Matches:      make([]map[graph.NodeIndex]graph.NodeIndex, len(gs)-1),
+	}
+
+	n := 0
+	for i, g := range res.Constraints {
+		res.Matches[i] = map[graph.NodeIndex]graph.NodeIndex{}
+		if matches == nil {
+			continue
+		}
+
+		for _, cnidx := range g.Children(graph.NodeIndex{}) {
+			if n >= len(matches) {
+				return nil, fmt.Errorf("%w: got only %v matches", ErrInvalidMatches, len(matches))
+			}
+			res.Matches[i][cnidx] = matches[n]
+			res.annotate(i, g, cnidx, matches[n])
+			n++
+		}
+	}
+
+	if n != len(matches) {
+		return nil, fmt.Errorf("%w: got %v matches but needed %v", ErrInvalidMatches, len(matches), n)
+	}
+	return res, nil
+}
+
+func (r *Result) annotate(constraint int, g *graph.Graph, cnidx, anidx graph.NodeIndex) {
+	req := Requirement{Constraint: constraint, Node: cnidx}
+	if name, ok := g.Node(cnidx).Properties["name"].(string); ok {
+		req.Name = name
+	}
+
+	node := r.Architecture.Node(anidx)
+	reqs, _ := node.Properties["requirements"].([]Requirement)
+	node.Properties["requirements"] = append(reqs, req)
+}
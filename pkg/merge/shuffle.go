@@ -1,35 +1,229 @@
 package merge
 
-import "math/rand"
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+)
 
-// TODO document
+// An Order enumerates combinations of choices one at a time.
+// Each call returns the next combination, where choiceIds[i] is in range [0, ns[i]). When all combinations have been
+// returned, ok is false. The returned slice must not be modified by the caller.
+type Order func() (choiceIds []int, ok bool)
 
-type Shuffle func(ns []int) (choiceIds [][]int)
+// Shuffle creates an Order over all combinations of choices.
+// ns contains the number of options for every choice. Combinations are generated lazily, which means that neither
+// memory nor time before the first combination depend on the number of combinations. The product of all ns must fit
+// into an int.
+// weights may be nil, in which case all options are equally likely. Otherwise weights[i] contains the positive weights
+// of the ns[i] options of choice i. The weight of a combination is the product of the weights of its options.
+// Shuffles that make random decisions must draw them from rnd only, so that the same source yields the same order.
+type Shuffle func(ns []int, weights [][]float64, rnd *rand.Rand) Order
 
-func InOrder(ns []int) [][]int {
+// InOrder enumerates all combinations, incrementing the first choice fastest. Weights are ignored.
+func InOrder(ns []int, weights [][]float64, rnd *rand.Rand) Order {
+	total := count(ns)
+	i := 0
+	return func() ([]int, bool) {
+		if i >= total {
+			return nil, false
+		}
+		choiceIds := decode(i, ns)
+		i++
+		return choiceIds, true
+	}
+}
+
+// RandomOrder enumerates all combinations in a random order.
+// Every combination is returned exactly once. The probability of a combination to be returned next is proportional to
+// its weight among the ones that haven't been returned yet.
+// If all options are equally likely, the permutation is computed on the fly from a few random keys, so it doesn't
+// require storing the combinations. Otherwise, memory grows with the number of combinations that have been returned.
+func RandomOrder(ns []int, weights [][]float64, rnd *rand.Rand) Order {
+	if !isUniform(weights) {
+		return weightedOrder(ns, weights, rnd)
+	}
+
+	total := count(ns)
+	perm := newPermutation(uint64(total), rnd)
+	i := 0
+	return func() ([]int, bool) {
+		if i >= total {
+			return nil, false
+		}
+		choiceIds := decode(int(perm.at(uint64(i))), ns)
+		i++
+		return choiceIds, true
+	}
+}
+
+func isUniform(weights [][]float64) bool {
+	for _, ws := range weights {
+		for _, w := range ws {
+			if w != ws[0] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func count(ns []int) int {
 	total := 1
 	for _, n := range ns {
 		total *= n
 	}
+	return total
+}
+
+// decode turns an index into a combination, the first choice being the least significant.
+func decode(i int, ns []int) []int {
+	choiceIds := make([]int, len(ns))
+	for j, n := range ns {
+		choiceIds[j] = i % n
+		i /= n
+	}
+	return choiceIds
+}
+
+// permutation is a bijection on [0, n) that is realized by a Feistel network on the smallest domain of the form 2^2k
+// that contains n. Values outside of [0, n) are mapped again until they fall inside (cycle walking). Since the domain
+// is less than four times as big as n, few iterations are needed on average.
+type permutation struct {
+	n    uint64
+	half uint
+	mask uint64
+	keys [4]uint64
+}
+
+func newPermutation(n uint64, rnd *rand.Rand) *permutation {
+	half := uint(1)
+	if n > 1 {
+		half = uint(bits.Len64(n-1)+1) / 2
+	}
 
-	orders := make([][]int, total)
-	for i := range orders {
-		order := make([]int, len(ns))
-		exp := 1
-		for j, n := range ns {
-			order[j] = (i / exp) % n
-			exp *= n
+	p := &permutation{
+		n:    n,
+		half: half,
+		mask: 1<<half - 1,
+	}
+	for i := range p.keys {
+		p.keys[i] = rnd.Uint64()
+	}
+	return p
+}
+
+func (p *permutation) at(i uint64) uint64 {
+	for {
+		i = p.encrypt(i)
+		if i < p.n {
+			return i
+		}
+	}
+}
+
+func (p *permutation) encrypt(x uint64) uint64 {
+	l, r := x>>p.half, x&p.mask
+	for _, key := range p.keys {
+		l, r = r, l^(mix(r^key)&p.mask)
+	}
+	return l<<p.half | r
+}
+
+// mix is the finalizer of SplitMix64. It scrambles the bits of its input.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// weightedOrder draws combinations one choice at a time. The remaining weight of every option is its share of the
+// weight of combinations that haven't been drawn yet. It is tracked in a tree that contains only the prefixes of drawn
+// combinations.
+func weightedOrder(ns []int, weights [][]float64, rnd *rand.Rand) Order {
+	normalized := make([][]float64, len(ns))
+	for i, ws := range weights {
+		sum := 0.
+		for _, w := range ws {
+			sum += w
+		}
+		normalized[i] = make([]float64, len(ws))
+		for j, w := range ws {
+			normalized[i][j] = w / sum
 		}
-		orders[i] = order
 	}
 
-	return orders
+	// capacity[i] is the number of combinations of the choices from i on
+	capacity := make([]int, len(ns)+1)
+	capacity[len(ns)] = 1
+	for i := len(ns) - 1; i >= 0; i-- {
+		capacity[i] = capacity[i+1] * ns[i]
+	}
+
+	root := &drawn{}
+	return func() ([]int, bool) {
+		if root.count >= capacity[0] {
+			return nil, false
+		}
+
+		choiceIds := make([]int, len(ns))
+		path := make([]*drawn, len(ns)+1)
+		path[0] = root
+		for i, n := range ns {
+			node := path[i]
+			remaining := make([]float64, n)
+			sum := 0.
+			for j := range remaining {
+				child := node.children[j]
+				if child == nil {
+					remaining[j] = normalized[i][j]
+				} else if child.count < capacity[i+1] {
+					// floating point errors must not make an available option impossible
+					remaining[j] = math.Max(normalized[i][j]*(1-child.share), math.SmallestNonzeroFloat64)
+				}
+				sum += remaining[j]
+			}
+
+			x := rnd.Float64() * sum
+			j := 0
+			for ; j < n-1 && (x >= remaining[j] || remaining[j] == 0); j++ {
+				x -= remaining[j]
+			}
+			for remaining[j] == 0 {
+				j--
+			}
+
+			choiceIds[i] = j
+			if node.children == nil {
+				node.children = map[int]*drawn{}
+			}
+			if node.children[j] == nil {
+				node.children[j] = &drawn{}
+			}
+			path[i+1] = node.children[j]
+		}
+
+		// update shares from the leaf upwards
+		path[len(ns)].count++
+		path[len(ns)].share = 1
+		delta := 1.
+		for i := len(ns) - 1; i >= 0; i-- {
+			delta *= normalized[i][choiceIds[i]]
+			path[i].count++
+			path[i].share += delta
+		}
+
+		return choiceIds, true
+	}
 }
 
-func RandomOrder(ns []int) [][]int {
-	out := InOrder(ns)
-	rand.Shuffle(len(out), func(i, j int) {
-		out[i], out[j] = out[j], out[i]
-	})
-	return out
+// drawn holds information about the combinations with a common prefix that have been drawn already.
+// share is the fraction of the weight of all combinations with that prefix.
+type drawn struct {
+	count    int
+	share    float64
+	children map[int]*drawn
 }
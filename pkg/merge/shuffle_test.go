@@ -8,8 +8,16 @@ import (
 	"github.com/nilsbu/arch/pkg/merge"
 )
 
+func collect(order merge.Order) [][]int {
+	out := [][]int{}
+	for is, ok := order(); ok; is, ok = order() {
+		out = append(out, is)
+	}
+	return out
+}
+
 func TestInOrder(t *testing.T) {
-	order := merge.InOrder([]int{4, 3, 1, 2})
+	order := collect(merge.InOrder([]int{4, 3, 1, 2}, nil, nil))
 	expect := [][]int{
 		{0, 0, 0, 0},
 		{1, 0, 0, 0},
@@ -42,25 +50,118 @@ func TestInOrder(t *testing.T) {
 	}
 }
 
+func TestInOrderEmpty(t *testing.T) {
+	if order := collect(merge.InOrder([]int{3, 0}, nil, nil)); len(order) != 0 {
+		t.Errorf("expected no combinations but got %v", order)
+	}
+}
+
 func TestRandomOrder(t *testing.T) {
-	rand.Seed(42)
-	order := merge.RandomOrder([]int{4, 3, 1})
-	expect := [][]int{
-		{3, 1, 0},
-		{1, 2, 0},
-		{3, 0, 0},
-		{1, 1, 0},
-		{0, 2, 0},
-		{3, 2, 0},
-		{2, 0, 0},
-		{2, 2, 0},
-		{1, 0, 0},
-		{2, 1, 0},
-		{0, 0, 0},
-		{0, 1, 0},
+	for _, ns := range [][]int{
+		{},
+		{1},
+		{2},
+		{4, 3, 1},
+		{7, 5, 3},
+		{16, 16},
+		{1000},
+	} {
+		order := collect(merge.RandomOrder(ns, nil, rand.New(rand.NewSource(42))))
+
+		seen := map[int]bool{}
+		inOrder := 0
+		for i, is := range order {
+			key, exp := 0, 1
+			for j, n := range ns {
+				if is[j] < 0 || is[j] >= n {
+					t.Fatalf("%v: choice %v out of range in %v", ns, j, is)
+				}
+				key += is[j] * exp
+				exp *= n
+			}
+			if seen[key] {
+				t.Fatalf("%v: combination %v returned twice", ns, is)
+			}
+			seen[key] = true
+			if key == i {
+				inOrder++
+			}
+		}
+
+		if expect := len(collect(merge.InOrder(ns, nil, nil))); len(seen) != expect {
+			t.Errorf("%v: expected %v combinations but got %v", ns, expect, len(seen))
+		}
+		if len(order) >= 100 && inOrder == len(order) {
+			t.Errorf("%v: combinations weren't shuffled", ns)
+		}
 	}
-	if !reflect.DeepEqual(expect, order) {
-		t.Errorf("order wrong:\nexpect: %v\nactual: %v",
-			expect, order)
+}
+
+func TestRandomOrderIsReproducible(t *testing.T) {
+	a := collect(merge.RandomOrder([]int{5, 4, 3}, nil, rand.New(rand.NewSource(7))))
+	b := collect(merge.RandomOrder([]int{5, 4, 3}, nil, rand.New(rand.NewSource(7))))
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("same seed must produce same order:\nfirst:  %v\nsecond: %v", a, b)
+	}
+
+	c := collect(merge.RandomOrder([]int{5, 4, 3}, nil, rand.New(rand.NewSource(8))))
+	if reflect.DeepEqual(a, c) {
+		t.Error("different seeds should produce different orders")
+	}
+}
+
+func TestRandomOrderIsLazy(t *testing.T) {
+	// 2^60 combinations can't be materialized, the first ones must be available immediately anyway
+	ns := []int{1 << 20, 1 << 20, 1 << 20}
+	order := merge.RandomOrder(ns, nil, rand.New(rand.NewSource(1)))
+	for i := 0; i < 1000; i++ {
+		if _, ok := order(); !ok {
+			t.Fatalf("order ended after %v combinations", i)
+		}
+	}
+}
+
+func TestWeightedRandomOrder(t *testing.T) {
+	ns := []int{3, 2}
+	weights := [][]float64{{1, 8, 1}, {1, 3}}
+
+	first := map[[2]int]int{}
+	rnd := rand.New(rand.NewSource(3))
+	for i := 0; i < 2000; i++ {
+		order := collect(merge.RandomOrder(ns, weights, rnd))
+		if len(order) != 6 {
+			t.Fatalf("expected 6 combinations but got %v", order)
+		}
+		seen := map[[2]int]bool{}
+		for _, is := range order {
+			seen[[2]int{is[0], is[1]}] = true
+		}
+		if len(seen) != 6 {
+			t.Fatalf("combinations aren't unique: %v", order)
+		}
+		first[[2]int{order[0][0], order[0][1]}]++
+	}
+
+	// {1, 1} has weight 24 out of 40
+	if n := first[[2]int{1, 1}]; n < 1100 || n > 1300 {
+		t.Errorf("expected {1, 1} to come first about 1200 times but it did %v times", n)
+	}
+	// {0, 0} has weight 1 out of 40
+	if n := first[[2]int{0, 0}]; n > 100 {
+		t.Errorf("expected {0, 0} to come first about 50 times but it did %v times", n)
+	}
+}
+
+func TestWeightedRandomOrderIsLazy(t *testing.T) {
+	ns := []int{1 << 16, 1 << 16, 1 << 16}
+	ws := make([]float64, 1<<16)
+	for i := range ws {
+		ws[i] = float64(i%3 + 1)
+	}
+	order := merge.RandomOrder(ns, [][]float64{ws, ws, ws}, rand.New(rand.NewSource(1)))
+	for i := 0; i < 10; i++ {
+		if _, ok := order(); !ok {
+			t.Fatalf("order ended after %v combinations", i)
+		}
 	}
 }
@@ -0,0 +1,140 @@
+package merge
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nilsbu/arch/pkg/blueprint"
+	"github.com/nilsbu/arch/pkg/rule"
+)
+
+// A ValidationError lists all problems that Validate found. It matches ErrInvalidBlueprint and the problems.
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, err := range e.Problems {
+		lines[i] = "\n  " + err.Error()
+	}
+	return fmt.Sprintf("%v: %v problems:%v", ErrInvalidBlueprint, len(e.Problems), strings.Join(lines, ""))
+}
+
+// Is walks the problems itself since errors.Is doesn't follow Unwrap() []error before Go 1.20.
+func (e *ValidationError) Is(target error) bool {
+	if target == ErrInvalidBlueprint {
+		return true
+	}
+	for _, err := range e.Problems {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first problem that matches target.
+func (e *ValidationError) As(target interface{}) bool {
+	for _, err := range e.Problems {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks a blueprint and everything that can be reached from it before anything is built. Every block needs
+// exactly one rule that the resolver knows and its values must match the parameters that the rule declares, see
+// rule.Declarer. References to properties that don't exist are reported as well. If there are problems, a
+// *ValidationError is returned.
+func Validate(bp *blueprint.Blueprint, resolver *Resolver) error {
+	v := &validator{
+		resolver: resolver,
+		blocks:   map[*blueprint.Blueprint]bool{},
+		groups:   map[reference]bool{},
+	}
+	v.block(bp, "")
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validator walks blueprints in the same way as calcBlock, but visits each block and group only once.
+type validator struct {
+	resolver *Resolver
+	blocks   map[*blueprint.Blueprint]bool
+	groups   map[reference]bool
+	problems []error
+}
+
+// reference is a name as seen from a blueprint.
+type reference struct {
+	bp   *blueprint.Blueprint
+	name string
+}
+
+func (v *validator) block(bp *blueprint.Blueprint, path string) {
+	if v.blocks[bp] {
+		return
+	}
+	v.blocks[bp] = true
+
+	name := bp.Values(v.resolver.Name)
+	if len(name) != 1 {
+		v.problems = append(v.problems, &blueprint.PositionError{Position: locate(bp, path),
+			Err: fmt.Errorf("%w: '%v' must have exactly one value", ErrInvalidBlueprint, v.resolver.Name)})
+		return
+	}
+
+	r := v.resolver.Keys[name[0]]
+	if r == nil {
+		pos, _ := bp.Position(v.resolver.Name)
+		pos.Path = path
+		v.problems = append(v.problems, &blueprint.PositionError{Position: pos,
+			Err: fmt.Errorf("%w: key '%v' is not defined", ErrInvalidBlueprint, name[0])})
+		return
+	}
+
+	v.problems = append(v.problems, rule.Validate(r, bp)...)
+	for _, param := range r.ChildParams() {
+		for _, value := range bp.Values(param) {
+			v.value(bp, value, join(path, param))
+		}
+	}
+}
+
+func (v *validator) value(bp *blueprint.Blueprint, value, path string) {
+	if value == "" {
+		v.problems = append(v.problems, &blueprint.PositionError{Position: locate(bp, path),
+			Err: fmt.Errorf("%w: empty value doesn't name a block", ErrInvalidBlueprint)})
+		return
+	} else if value[0] == '*' {
+		if child := bp.Child(value); child == nil {
+			v.problems = append(v.problems, &blueprint.PositionError{Position: locate(bp, path),
+				Err: fmt.Errorf("%w: '%v' is not defined", ErrInvalidBlueprint, value)})
+		} else {
+			v.block(child, path)
+		}
+		return
+	}
+
+	path = join(path, value)
+	if key := (reference{bp, value}); v.groups[key] {
+		return
+	} else {
+		v.groups[key] = true
+	}
+
+	values := bp.Values(value)
+	if values == nil {
+		v.problems = append(v.problems, &blueprint.PositionError{Position: locate(bp, path),
+			Err: fmt.Errorf("%w: '%v' is not defined", ErrInvalidBlueprint, value)})
+	}
+	for _, inner := range values {
+		v.value(bp, inner, path)
+	}
+}
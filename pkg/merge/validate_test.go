@@ -0,0 +1,149 @@
+package merge_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nilsbu/arch/pkg/blueprint"
+	"github.com/nilsbu/arch/pkg/merge"
+	"github.com/nilsbu/arch/pkg/rule"
+	tr "github.com/nilsbu/arch/test/rule"
+)
+
+func TestValidate(t *testing.T) {
+	resolver := &merge.Resolver{
+		Name: "@",
+		Keys: map[string]rule.Rule{
+			"1":         &tr.RuleMock{Params: []string{"a"}},
+			"R":         &tr.RuleMock{},
+			"House":     &rule.House{},
+			"Occupy":    &rule.Occupy{},
+			"Furniture": &rule.Furniture{},
+			"Corridor":  &rule.Corridor{},
+			"Frame":     &rule.Frame{},
+		},
+	}
+
+	for _, c := range []struct {
+		name     string
+		json     string
+		problems []string
+	}{
+		{
+			"valid",
+			`{"@":"1","a":{"@":"R"}}`,
+			nil,
+		},
+		{
+			"valid rules",
+			`{"@":"House","rect":[0,0,8,4],"interior":{"@":"Occupy","texture":2},"exterior":{"@":"R"}}`,
+			nil,
+		},
+		{
+			"recursive blueprint is visited once",
+			`{"@":"1","a":"c","c":[{"@":"1","a":"c"},{"@":"R"}]}`,
+			nil,
+		},
+		{
+			"all problems are reported",
+			`{
+				"@":"1",
+				"a":[
+					{"@":["R","R"]},
+					{"@":"X"},
+					{"@":"Occupy"},
+					"missing"]
+			}`,
+			[]string{
+				"line 4 at a: invalid blueprint: '@' must have exactly one value",
+				"line 5 at a: invalid blueprint: key 'X' is not defined",
+				"line 6 at a/2: invalid parameter: 'texture' is required",
+				"'missing' is not defined",
+			},
+		},
+		{
+			"empty child",
+			`{"@":"House","rect":[0,0,8,4],"interior":"","exterior":{"@":"R"}}`,
+			[]string{"invalid blueprint: empty value doesn't name a block"},
+		},
+		{
+			"wrong types and counts",
+			`{"@":"House","rect":[0,0,8],"interior":{"@":"Occupy","texture":"wood"},"exterior":{"@":"R"}}`,
+			[]string{
+				"line 1 at rect: invalid parameter: 'rect' must have 4 values but has 3",
+				"'texture' has invalid value: value has wrong type: expected integer but got \"wood\"",
+			},
+		},
+		{
+			"no children",
+			`{"@":"Corridor","left":[],"corridor":{"@":"R"}}`,
+			[]string{
+				"line 1 at left: invalid parameter: 'left' must have at least 1 values for children but has 0",
+				"'right' must have at least 1 values for children but has 0",
+			},
+		},
+		{
+			"too many children",
+			`{"@":"Frame","content":[{"@":"R"},{"@":"R"}]}`,
+			[]string{"line 1 at content: invalid parameter: 'content' must have 1 values for children but has 2"},
+		},
+		{
+			"furniture",
+			`{
+				"@":"Furniture",
+				"elements":[{"@":"R"},{"@":"R"}],
+				"sizes":[[1,2],[1,2,3]],
+				"anchors":["center","middle"]
+			}`,
+			[]string{
+				"line 5 at anchors: invalid parameter: 'anchors' has invalid value 'middle', allowed are: center,",
+			},
+		},
+		{
+			"furniture counts",
+			`{"@":"Furniture","elements":[{"@":"R"},{"@":"R"}],"sizes":[[1,2]],"anchors":"center"}`,
+			[]string{
+				"'sizes' must have as many values as 'elements' (2) but has 1",
+				"'anchors' must have as many values as 'elements' (2) but has 1",
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bp, err := blueprint.Parse([]byte(c.json))
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			err = merge.Validate(bp, resolver)
+			if len(c.problems) == 0 {
+				if err != nil {
+					t.Fatal("unexpected error:", err)
+				}
+				return
+			}
+
+			var ve *merge.ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("expected *merge.ValidationError but got %v", err)
+			} else if !errors.Is(err, merge.ErrInvalidBlueprint) {
+				t.Error("error must match ErrInvalidBlueprint")
+			}
+			var pe *blueprint.PositionError
+			if !errors.As(err, &pe) || pe != ve.Problems[0] {
+				t.Error("error must match its first positioned problem")
+			}
+			if len(ve.Problems) != len(c.problems) {
+				t.Fatalf("expected %v problems but got %v:\n%v", len(c.problems), len(ve.Problems), err)
+			}
+			for i, p := range c.problems {
+				if !strings.Contains(ve.Problems[i].Error(), p) {
+					t.Errorf("problem %v: expected '%v' in '%v'", i, p, ve.Problems[i])
+				}
+				if !errors.Is(err, ve.Problems[i]) {
+					t.Errorf("problem %v: error doesn't match it", i)
+				}
+			}
+		})
+	}
+}
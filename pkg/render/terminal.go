@@ -72,6 +72,12 @@ func getChar(data *world.Tiles, x, y int) (rune, error) {
 		return wall(data, x, y), nil
 	case world.Door:
 		return ' ', nil
+	case world.Archway:
+		return '·', nil
+	case world.Gate:
+		return '+', nil
+	case world.LockedDoor:
+		return '×', nil
 	case world.Occupied:
 		if r, ok := occupiedChars[tile.Texture]; ok {
 			return r, nil
@@ -83,18 +89,23 @@ func getChar(data *world.Tiles, x, y int) (rune, error) {
 	}
 }
 
+// connects returns whether a wall continues into a tile.
+func connects(tile world.Tile) bool {
+	return tile.Type == world.Wall || tile.Type.IsPassage()
+}
+
 func wall(data *world.Tiles, x, y int) rune {
 	var o area.Direction
-	if x > 0 && (data.Get(x-1, y).Type == world.Wall || data.Get(x-1, y).Type == world.Door) {
+	if x > 0 && connects(data.Get(x-1, y)) {
 		o |= area.Left
 	}
-	if x+1 < data.Width() && (data.Get(x+1, y).Type == world.Wall || data.Get(x+1, y).Type == world.Door) {
+	if x+1 < data.Width() && connects(data.Get(x+1, y)) {
 		o |= area.Right
 	}
-	if y > 0 && (data.Get(x, y-1).Type == world.Wall || data.Get(x, y-1).Type == world.Door) {
+	if y > 0 && connects(data.Get(x, y-1)) {
 		o |= area.Up
 	}
-	if y+1 < data.Height() && (data.Get(x, y+1).Type == world.Wall || data.Get(x, y+1).Type == world.Door) {
+	if y+1 < data.Height() && connects(data.Get(x, y+1)) {
 		o |= area.Down
 	}
 
@@ -62,6 +62,39 @@ func TestTerminal(t *testing.T) {
 				t0 + 2, int(' '), int(' '), int('.'), t0 + 2, 10,
 				t0 + 20, t0, t0, t0, t0 + 24, 10}),
 		},
+		{
+			"passages",
+			func() *world.Tiles {
+				data := world.CreateTiles(5, 1, world.Tile{Type: world.Wall})
+				world.DrawRectangle(data, 1, 0, 1, 0, world.Tile{Type: world.Archway})
+				world.DrawRectangle(data, 2, 0, 2, 0, world.Tile{Type: world.Gate})
+				world.DrawRectangle(data, 3, 0, 3, 0, world.Tile{Type: world.LockedDoor})
+				return data
+			},
+			true,
+			toStr([]int{
+				t0 + 12, t0, t0, t0, t0, t0, t0 + 16, 10,
+				t0 + 2, 9552, int('·'), int('+'), int('×'), 9552, t0 + 2, 10,
+				t0 + 20, t0, t0, t0, t0, t0, t0 + 24, 10}),
+		},
+		{
+			"walls connect through passages",
+			func() *world.Tiles {
+				data := world.CreateTiles(1, 5, world.Tile{Type: world.Wall})
+				world.DrawRectangle(data, 0, 1, 0, 2, world.Tile{Type: world.Archway})
+				world.DrawRectangle(data, 0, 3, 0, 3, world.Tile{Type: world.LockedDoor})
+				return data
+			},
+			true,
+			toStr([]int{
+				t0 + 12, t0, t0 + 16, 10,
+				t0 + 2, 9553, t0 + 2, 10,
+				t0 + 2, int('·'), t0 + 2, 10,
+				t0 + 2, int('·'), t0 + 2, 10,
+				t0 + 2, int('×'), t0 + 2, 10,
+				t0 + 2, 9553, t0 + 2, 10,
+				t0 + 20, t0, t0 + 24, 10}),
+		},
 		{
 			"all wall characters", // exception: isolated wall, that's in the next test
 			func() *world.Tiles {
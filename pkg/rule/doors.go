@@ -0,0 +1,129 @@
+package rule
+
+import (
+	"fmt"
+
+	"github.com/nilsbu/arch/pkg/area"
+	"github.com/nilsbu/arch/pkg/blueprint"
+	"github.com/nilsbu/arch/pkg/graph"
+)
+
+// doorParams configure the doors that a rule creates between the areas it lays out.
+// "door" is the position of the doors along the wall. It is either a number in [0, 1], where 0 is the left end as seen
+// from the first area towards the second one, a distribution like "uniform(0.2,0.8)" to place them randomly, or one of
+// "left", "center" and "right". "left" and "right" put the doors next to the corner at that end. The default is the
+// center.
+// "doors" is the number of doors between two areas, 1 by default. Several doors divide the wall into equal sections
+// with one door each, positioned within its section.
+// "door-width" is the number of tiles that each door takes up, 1 by default, and "door-kind" one of "door",
+// "archway", "gate" and "locked".
+var doorParams = []Param{
+	{Name: "door", Type: AnyValue, Max: 1},
+	{Name: "doors", Type: IntValue, Max: 1},
+	{Name: "door-width", Type: IntValue, Max: 1},
+	{Name: "door-kind", Type: StringValue, Max: 1, Enum: []string{"door", "archway", "gate", "locked"}},
+}
+
+// doorSpec describes the doors between two areas as doorParams define them.
+type doorSpec struct {
+	count    int
+	width    int
+	kind     area.DoorKind
+	position float64
+	// end is "left" or "right" if the doors are put next to that end of their section.
+	end string
+}
+
+func readDoors(bp *blueprint.Blueprint) (doorSpec, error) {
+	spec := doorSpec{count: 1, width: 1, kind: area.Door, position: .5}
+	if values := bp.TypedValues("door"); len(values) > 0 {
+		if name, err := values[0].Text(); err == nil {
+			if name == "left" || name == "right" {
+				spec.end = name
+			} else if name != "center" {
+				return spec, atProperty(bp, "door", fmt.Errorf(
+					"%w: 'door' must be a number, 'left', 'center' or 'right' but is '%v'", ErrPreparation, name))
+			}
+		} else if pos, err := values[0].Float(); err != nil {
+			return spec, atProperty(bp, "door", err)
+		} else if pos < 0 || pos > 1 {
+			return spec, atProperty(bp, "door",
+				fmt.Errorf("%w: 'door' must be in [0, 1] but is %v", ErrPreparation, pos))
+		} else {
+			spec.position = pos
+		}
+	}
+
+	if count, err := intOr(bp, "doors", 1); err != nil {
+		return spec, err
+	} else if count < 1 {
+		return spec, atProperty(bp, "doors", fmt.Errorf("%w: 'doors' must be positive", ErrPreparation))
+	} else {
+		spec.count = count
+	}
+
+	if width, err := intOr(bp, "door-width", 1); err != nil {
+		return spec, err
+	} else if width < 1 {
+		return spec, atProperty(bp, "door-width", fmt.Errorf("%w: 'door-width' must be positive", ErrPreparation))
+	} else {
+		spec.width = width
+	}
+
+	if values := bp.Values("door-kind"); len(values) > 0 {
+		if kind, err := area.DoorKindByName(values[0]); err != nil {
+			return spec, atProperty(bp, "door-kind", fmt.Errorf("%w: %v", ErrPreparation, err))
+		} else {
+			spec.kind = kind
+		}
+	}
+	return spec, nil
+}
+
+// create connects two neighbouring areas through the doors. ErrInvalidGraph is returned if the wall between them is
+// too short to fit the doors without covering a corner or without a wall tile between two of them.
+func (s doorSpec) create(g *graph.Graph, nidx0, nidx1 graph.NodeIndex) error {
+	line, ok := area.Border((*area.AreaNode)(g.Node(nidx0)).GetRect(), (*area.AreaNode)(g.Node(nidx1)).GetRect())
+	if !ok {
+		// CreateDoor explains why the areas cannot be connected
+		return invalid(area.CreateDoor(g, nidx0, nidx1, s.position))
+	}
+
+	// Seen from the first area, an opening spans the tiles from its middle - left to its middle + right.
+	length := float64(line.X1 - line.X0 + line.Y1 - line.Y0)
+	left, right := float64((s.width-1)/2), float64(s.width/2)
+	section := length / float64(s.count)
+	var prev area.Rectangle
+	for i := 0; i < s.count; i++ {
+		var at float64
+		if s.end == "left" {
+			at = float64(i)*section + 1 + left
+		} else if s.end == "right" {
+			at = float64(i+1)*section - 1 - right
+		} else {
+			at = (float64(i) + s.position) * section
+		}
+
+		if at-left < 1 || at+right > length-1 {
+			return fmt.Errorf("%w: a wall of length %v has no room for %v doors of width %v",
+				ErrNoRoomForDoors, length, s.count, s.width)
+		} else if err := area.CreateOpening(g, nidx0, nidx1, at/length, s.width, s.kind); err != nil {
+			return invalid(err)
+		}
+
+		// CreateOpening rounds the position, so the spacing is checked on the tiles that the doors end up on.
+		edges := g.Node(nidx0).Edges
+		opening := (*area.DoorEdge)(g.Edge(edges[len(edges)-1])).GetOpening()
+		if i > 0 && !apart(prev, opening) {
+			return fmt.Errorf("%w: a wall of length %v has no room for %v doors of width %v",
+				ErrNoRoomForDoors, length, s.count, s.width)
+		}
+		prev = opening
+	}
+	return nil
+}
+
+// apart checks if there is at least one tile between two openings on the same line.
+func apart(a, b area.Rectangle) bool {
+	return a.X1+1 < b.X0 || b.X1+1 < a.X0 || a.Y1+1 < b.Y0 || b.Y1+1 < a.Y0
+}
@@ -0,0 +1,131 @@
+package rule_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/nilsbu/arch/pkg/area"
+	"github.com/nilsbu/arch/pkg/blueprint"
+	"github.com/nilsbu/arch/pkg/graph"
+	"github.com/nilsbu/arch/pkg/merge"
+	"github.com/nilsbu/arch/pkg/rule"
+)
+
+// openings returns the openings of the doors of a node.
+func openings(g *graph.Graph, nidx graph.NodeIndex) []area.Rectangle {
+	var out []area.Rectangle
+	for _, eidx := range g.Node(nidx).Edges {
+		out = append(out, (*area.DoorEdge)(g.Edge(eidx)).GetOpening())
+	}
+	return out
+}
+
+func TestDoors(t *testing.T) {
+	// The rooms of a RoomLine are connected through a wall of length 8 at y = 6. The first room lies below the second
+	// one, so CreateDoor puts position 0 at x = 8.
+	for _, c := range []struct {
+		name     string
+		json     string
+		openings []area.Rectangle
+		err      error
+	}{
+		{"default", `{}`, []area.Rectangle{{X0: 4, Y0: 6, X1: 4, Y1: 6}}, nil},
+		{"center", `{"door":"center"}`, []area.Rectangle{{X0: 4, Y0: 6, X1: 4, Y1: 6}}, nil},
+		{"left", `{"door":"left"}`, []area.Rectangle{{X0: 7, Y0: 6, X1: 7, Y1: 6}}, nil},
+		{"right", `{"door":"right"}`, []area.Rectangle{{X0: 1, Y0: 6, X1: 1, Y1: 6}}, nil},
+		{"number", `{"door":0.25}`, []area.Rectangle{{X0: 6, Y0: 6, X1: 6, Y1: 6}}, nil},
+		{
+			"wide door on the left", `{"door":"left","door-width":3}`,
+			[]area.Rectangle{{X0: 5, Y0: 6, X1: 7, Y1: 6}},
+			nil,
+		},
+		{
+			"two doors", `{"doors":2}`,
+			[]area.Rectangle{{X0: 6, Y0: 6, X1: 6, Y1: 6}, {X0: 2, Y0: 6, X1: 2, Y1: 6}},
+			nil,
+		},
+		{
+			"two doors on the left of their sections", `{"doors":2,"door":"left"}`,
+			[]area.Rectangle{{X0: 7, Y0: 6, X1: 7, Y1: 6}, {X0: 3, Y0: 6, X1: 3, Y1: 6}},
+			nil,
+		},
+		{
+			"three doors", `{"doors":3}`,
+			[]area.Rectangle{
+				{X0: 7, Y0: 6, X1: 7, Y1: 6}, {X0: 4, Y0: 6, X1: 4, Y1: 6}, {X0: 1, Y0: 6, X1: 1, Y1: 6},
+			},
+			nil,
+		},
+		{"unknown name", `{"door":"middle"}`, nil, rule.ErrPreparation},
+		{"out of range", `{"door":1.5}`, nil, rule.ErrPreparation},
+		{"no doors", `{"doors":0}`, nil, rule.ErrPreparation},
+		{"no width", `{"door-width":0}`, nil, rule.ErrPreparation},
+		{"at corner", `{"door":0}`, nil, rule.ErrInvalidGraph},
+		{"too many doors", `{"doors":5}`, nil, rule.ErrInvalidGraph},
+		{"doors too close", `{"doors":3,"door-width":2,"door":0.6}`, nil, rule.ErrInvalidGraph},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			g, children, err := prepare(t, rule.RoomLine{}, 8, 12, map[string]int{"rooms": 2}, c.json)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("expected %v but got %v", c.err, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			// the second room has no other doors
+			if actual := openings(g, children["rooms"][1]); !reflect.DeepEqual(c.openings, actual) {
+				t.Errorf("expected openings %v but got %v", c.openings, actual)
+			}
+		})
+	}
+}
+
+type fits struct{}
+
+func (fits) Match(context.Context, []*graph.Graph) (bool, []graph.NodeIndex, error) {
+	return true, nil, nil
+}
+
+func TestDoorDistribution(t *testing.T) {
+	bp, err := blueprint.Parse([]byte(`{
+		"@rule": "House",
+		"rect": [0, 0, 20, 8],
+		"interior": {"@rule": "RoomLine", "rooms": ["R", "R"], "door": "uniform(0.2,0.8)"},
+		"exterior": {"@rule": "NOP"},
+		"R": {"@rule": "Room"}
+	}`))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	resolver := &merge.Resolver{Name: "@rule", Keys: map[string]rule.Rule{
+		"House": rule.House{}, "RoomLine": rule.RoomLine{}, "Room": rule.Room{}, "NOP": rule.NOP{},
+	}}
+
+	seen := map[int]bool{}
+	for seed := int64(0); seed < 10; seed++ {
+		res, err := merge.Build(context.Background(), []*blueprint.Blueprint{bp}, fits{}, resolver,
+			merge.RandomOrder, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		// the rooms of the line are the leaves with a single door
+		for _, nidx := range res.Architecture.Children(res.Architecture.Children(graph.NodeIndex{})[0]) {
+			if ops := openings(res.Architecture, nidx); len(ops) == 1 {
+				if ops[0].X0 < 4 || ops[0].X0 > 16 {
+					t.Errorf("seed %v: door at %v lies outside of the distribution", seed, ops[0])
+				}
+				seen[ops[0].X0] = true
+			}
+		}
+	}
+	if len(seen) < 2 {
+		t.Errorf("expected doors at different positions but got %v", seen)
+	}
+}
@@ -0,0 +1,11 @@
+package rule
+
+import (
+	"github.com/nilsbu/arch/pkg/area"
+	"github.com/nilsbu/arch/pkg/graph"
+)
+
+// Connect exposes connect with a single door in the middle of the wall.
+func Connect(g *graph.Graph, first, second []graph.NodeIndex) error {
+	return connect(g, first, second, doorSpec{count: 1, width: 1, kind: area.Door, position: .5})
+}
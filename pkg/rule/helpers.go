@@ -48,17 +48,21 @@ func passOn(
 	nidx graph.NodeIndex,
 	nidxs []graph.NodeIndex,
 	rects []area.Rectangle) error {
-	door := (*area.DoorEdge)(g.Edge(eidx)).GetPos()
+	door := (*area.DoorEdge)(g.Edge(eidx))
+	pos, opening := door.GetPos(), door.GetOpening()
 
+	// the whole opening must lie on one side of a child without touching its corners
 	for i, rect := range rects {
-		if ((rect.X0 == door.X || rect.X1 == door.X) && rect.Y0 < door.Y && rect.Y1 > door.Y) ||
-			((rect.Y0 == door.Y || rect.Y1 == door.Y) && rect.X0 < door.X && rect.X1 > door.X) {
+		if ((rect.X0 == opening.X0 || rect.X1 == opening.X0) && opening.X0 == opening.X1 &&
+			rect.Y0 < opening.Y0 && rect.Y1 > opening.Y1) ||
+			((rect.Y0 == opening.Y0 || rect.Y1 == opening.Y0) && opening.Y0 == opening.Y1 &&
+				rect.X0 < opening.X0 && rect.X1 > opening.X1) {
 			return g.InheritEdge(nidx, nidxs[i], []graph.EdgeIndex{eidx})
 		}
 	}
 
-	return fmt.Errorf("%w: door %v at [%v, %v] cannot be assigned to child area", ErrInvalidGraph,
-		eidx, door.X, door.Y)
+	return fmt.Errorf("%w: door %v at [%v, %v] lies on no single child area", ErrUnassignableDoor,
+		eidx, pos.X, pos.Y)
 }
 
 // SetWall enables or disables walls for an area.
@@ -0,0 +1,61 @@
+package rule_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nilsbu/arch/pkg/area"
+	"github.com/nilsbu/arch/pkg/graph"
+	"github.com/nilsbu/arch/pkg/rule"
+)
+
+func TestInheritWideDoor(t *testing.T) {
+	// The archway spans the tiles from x = 5 to x = 7 at the bottom of the area.
+	for _, c := range []struct {
+		name     string
+		border   int
+		inherits int
+		err      error
+	}{
+		{"passed on to the right", 4, 1, nil},
+		{"passed on to the left", 8, 0, nil},
+		{"spans both children", 6, 0, rule.ErrInvalidGraph},
+		{"touches a corner", 5, 0, rule.ErrInvalidGraph},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			g := graph.New(nil)
+			(*area.AreaNode)(g.Node(graph.NodeIndex{})).SetRect(area.Rectangle{X0: 0, Y0: 0, X1: 12, Y1: 10})
+			nidx, _ := g.Add(graph.NodeIndex{})
+			(*area.AreaNode)(g.Node(nidx)).SetRect(area.Rectangle{X0: 0, Y0: 0, X1: 12, Y1: 8})
+			outside, _ := g.Add(graph.NodeIndex{})
+			(*area.AreaNode)(g.Node(outside)).SetRect(area.Rectangle{X0: 0, Y0: 8, X1: 12, Y1: 10})
+			if err := area.CreateOpening(g, outside, nidx, .5, 3, area.Archway); err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			left, _ := g.Add(nidx)
+			(*area.AreaNode)(g.Node(left)).SetRect(area.Rectangle{X0: 0, Y0: 0, X1: c.border, Y1: 8})
+			right, _ := g.Add(nidx)
+			(*area.AreaNode)(g.Node(right)).SetRect(area.Rectangle{X0: c.border, Y0: 0, X1: 12, Y1: 8})
+
+			if err := rule.InheritEdges(g, nidx); c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("expected %v but got %v", c.err, err)
+				}
+			} else if err != nil {
+				t.Fatal("unexpected error:", err)
+			} else {
+				children := []graph.NodeIndex{left, right}
+				for i, cnidx := range children {
+					expect := 0
+					if i == c.inherits {
+						expect = 1
+					}
+					if n := len(g.Node(cnidx).Edges); n != expect {
+						t.Errorf("child %v: expected %v doors but got %v", i, expect, n)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package rule
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/nilsbu/arch/pkg/area"
+	"github.com/nilsbu/arch/pkg/blueprint"
+	"github.com/nilsbu/arch/pkg/graph"
+)
+
+// Grid arranges its cells in "rows" rows of "columns" cells each, which are listed row by row. The rows follow each
+// other in the direction of the area's orientation. Neighbouring cells are connected by doors.
+type Grid struct{}
+
+func (r Grid) ChildParams() []string {
+	return []string{"cells"}
+}
+
+func (r Grid) ValueParams() []Param {
+	return append([]Param{
+		{Name: "rows", Type: IntValue, Required: true, Min: 1, Max: 1},
+		{Name: "columns", Type: IntValue, Required: true, Min: 1, Max: 1},
+	}, doorParams...)
+}
+
+func (r Grid) PrepareGraph(
+	g *graph.Graph,
+	nidx graph.NodeIndex,
+	children map[string][]graph.NodeIndex,
+	bp *blueprint.Blueprint,
+) error {
+	g.Node(nidx).Properties["render"] = false
+	cells := children["cells"]
+
+	rows, err := bp.Int("rows")
+	if err != nil {
+		return err
+	} else if rows < 1 {
+		return atProperty(bp, "rows", fmt.Errorf("%w: 'rows' must be positive", ErrPreparation))
+	}
+	columns, err := bp.Int("columns")
+	if err != nil {
+		return err
+	} else if columns < 1 {
+		return atProperty(bp, "columns", fmt.Errorf("%w: 'columns' must be positive", ErrPreparation))
+	} else if len(cells) != rows*columns {
+		return atProperty(bp, "cells", fmt.Errorf("%w: have %v cells for %v rows and %v columns",
+			ErrPreparation, len(cells), rows, columns))
+	}
+	doors, err := readDoors(bp)
+	if err != nil {
+		return err
+	}
+
+	// Each row is first put into its first cell, which is then split into the row's cells.
+	orientation := RoomOrientation(g, nidx)
+	firsts := make([]graph.NodeIndex, rows)
+	for i := range firsts {
+		firsts[i] = cells[i*columns]
+	}
+	if err := area.SplitShares(g, nidx, firsts, equalShares(rows), orientation); err != nil {
+		return invalid(err)
+	}
+	for i := 0; i < rows; i++ {
+		row := cells[i*columns : (i+1)*columns]
+		if err := area.SplitShares(g, row[0], row, equalShares(columns), area.Turn(orientation, 90)); err != nil {
+			return invalid(err)
+		}
+	}
+
+	for i, cnidx := range cells {
+		if i%columns > 0 {
+			if err := doors.create(g, cells[i-1], cnidx); err != nil {
+				return err
+			}
+		}
+		if i >= columns {
+			if err := doors.create(g, cells[i-columns], cnidx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return InheritEdges(g, nidx)
+}
+
+func equalShares(n int) []area.Share {
+	shares := make([]area.Share, n)
+	for i := range shares {
+		shares[i].Weight = 1
+	}
+	return shares
+}
+
+// BSP partitions its area into its rooms by binary space partitioning: The area is cut in two across its longer side,
+// half of the rooms are put on either side and both halves are partitioned further until every room has its own
+// area. Each side of a cut keeps at least "min-size" tiles, 3 by default, per room on it. Rooms on opposite sides of
+// a cut are connected where they share the longest wall.
+// "ratio" is the part of the area that the first half of the rooms gets at a cut. For a distribution like
+// "uniform(0.3,0.7)", a new ratio is drawn for every cut. By default, areas are cut in proportion to the number of
+// rooms on each side.
+type BSP struct{}
+
+func (r BSP) ChildParams() []string {
+	return []string{"rooms"}
+}
+
+func (r BSP) ChildCounts() []Param {
+	return []Param{{Name: "rooms", Min: 1}}
+}
+
+func (r BSP) ValueParams() []Param {
+	return append([]Param{
+		{Name: "min-size", Type: IntValue, Max: 1},
+		{Name: "ratio", Type: FloatValue, Max: 1, Drawn: true},
+	}, doorParams...)
+}
+
+// PrepareGraph draws the same ratios every time, see PrepareGraphWithRand.
+func (r BSP) PrepareGraph(
+	g *graph.Graph,
+	nidx graph.NodeIndex,
+	children map[string][]graph.NodeIndex,
+	bp *blueprint.Blueprint,
+) error {
+	return r.PrepareGraphWithRand(g, nidx, children, bp, rand.New(rand.NewSource(0)))
+}
+
+func (r BSP) PrepareGraphWithRand(
+	g *graph.Graph,
+	nidx graph.NodeIndex,
+	children map[string][]graph.NodeIndex,
+	bp *blueprint.Blueprint,
+	rnd *rand.Rand,
+) error {
+	g.Node(nidx).Properties["render"] = false
+
+	if len(children["rooms"]) == 0 {
+		return atProperty(bp, "rooms", fmt.Errorf("%w: there are no rooms to partition", ErrPreparation))
+	}
+	minSize, err := intOr(bp, "min-size", 3)
+	if err != nil {
+		return err
+	} else if minSize < 1 {
+		return atProperty(bp, "min-size", fmt.Errorf("%w: 'min-size' must be positive", ErrPreparation))
+	}
+	p := &partitioner{g: g, minSize: minSize}
+	if values := bp.TypedValues("ratio"); len(values) > 0 {
+		if p.ratio, err = drawRatio(values[0], rnd); err != nil {
+			return atProperty(bp, "ratio", err)
+		}
+	}
+	if p.doors, err = readDoors(bp); err != nil {
+		return err
+	}
+
+	rect := (*area.AreaNode)(g.Node(nidx)).GetRect()
+	if rect.X1-rect.X0-1 < minSize || rect.Y1-rect.Y0-1 < minSize {
+		return fmt.Errorf("%w: area %v is smaller than the minimum size %v", ErrTooSmall, rect, minSize)
+	} else if err := p.partition(nidx, children["rooms"]); err != nil {
+		return err
+	}
+	return InheritEdges(g, nidx)
+}
+
+// partitioner holds the state of a BSP while it partitions an area.
+type partitioner struct {
+	g       *graph.Graph
+	minSize int
+	// ratio returns the ratio of the next cut, it is nil if the cuts follow the number of rooms
+	ratio func() float64
+	doors doorSpec
+}
+
+// drawRatio returns a function that returns a ratio. If the value is a distribution, every call draws a new one.
+func drawRatio(v blueprint.Value, rnd *rand.Rand) (func() float64, error) {
+	if d, ok := v.Distribution(); ok {
+		if !(d.Min > 0 && d.Max < 1) {
+			return nil, fmt.Errorf("%w: 'ratio' must be in (0, 1) but is %v", ErrPreparation, d)
+		}
+		return func() float64 {
+			ratio, _ := d.Sample(rnd).Float()
+			return ratio
+		}, nil
+	} else if ratio, err := v.Float(); err != nil {
+		return nil, err
+	} else if !(ratio > 0 && ratio < 1) {
+		return nil, fmt.Errorf("%w: 'ratio' must be in (0, 1) but is %v", ErrPreparation, ratio)
+	} else {
+		return func() float64 { return ratio }, nil
+	}
+}
+
+// partition splits the area of base among rooms. base may be the first of the rooms.
+func (p *partitioner) partition(base graph.NodeIndex, rooms []graph.NodeIndex) error {
+	if len(rooms) == 1 {
+		return area.Split(p.g, base, rooms, []float64{}, area.Down)
+	}
+
+	rect := (*area.AreaNode)(p.g.Node(base)).GetRect()
+	direction := area.Down
+	if rect.X1-rect.X0 > rect.Y1-rect.Y0 {
+		direction = area.Right
+	}
+
+	half := len(rooms) / 2
+	ratio := float64(half) / float64(len(rooms))
+	if p.ratio != nil {
+		ratio = p.ratio()
+	}
+	first, second := rooms[:half], rooms[half:]
+	shares := []area.Share{
+		{Weight: ratio, Min: len(first) * p.minSize},
+		{Weight: 1 - ratio, Min: len(second) * p.minSize},
+	}
+
+	if err := area.SplitShares(p.g, base, []graph.NodeIndex{first[0], second[0]}, shares, direction); err != nil {
+		return invalid(err)
+	} else if err := p.partition(first[0], first); err != nil {
+		return err
+	} else if err := p.partition(second[0], second); err != nil {
+		return err
+	} else {
+		return connect(p.g, first, second, p.doors)
+	}
+}
+
+// connect creates doors between the two rooms from both groups that share the longest wall.
+func connect(g *graph.Graph, first, second []graph.NodeIndex, doors doorSpec) error {
+	var best [2]graph.NodeIndex
+	bestLength := 0
+	for _, a := range first {
+		for _, b := range second {
+			line, ok := area.Border((*area.AreaNode)(g.Node(a)).GetRect(), (*area.AreaNode)(g.Node(b)).GetRect())
+			// a door needs a wall tile that isn't part of a corner
+			if length := line.X1 - line.X0 + line.Y1 - line.Y0; ok && length > 1 && length > bestLength {
+				best, bestLength = [2]graph.NodeIndex{a, b}, length
+			}
+		}
+	}
+
+	if bestLength == 0 {
+		return fmt.Errorf("%w: no rooms on both sides of a cut share a wall", ErrNoSharedWall)
+	}
+	return doors.create(g, best[0], best[1])
+}
@@ -0,0 +1,212 @@
+package rule_test
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/nilsbu/arch/pkg/area"
+	"github.com/nilsbu/arch/pkg/blueprint"
+	"github.com/nilsbu/arch/pkg/graph"
+	"github.com/nilsbu/arch/pkg/rule"
+)
+
+// edges counts the edges of nodes.
+func edges(g *graph.Graph, nidxs []graph.NodeIndex) []int {
+	out := make([]int, len(nidxs))
+	for i, nidx := range nidxs {
+		out[i] = len(g.Node(nidx).Edges)
+	}
+	return out
+}
+
+func TestGrid(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		json  string
+		cells int
+		rects []area.Rectangle
+		edges []int
+		err   error
+	}{
+		{
+			"two rows of three", `{"rows":2,"columns":3}`, 6,
+			[]area.Rectangle{
+				{X0: 0, Y0: 4, X1: 4, Y1: 8}, {X0: 4, Y0: 4, X1: 8, Y1: 8}, {X0: 8, Y0: 4, X1: 12, Y1: 8},
+				{X0: 0, Y0: 0, X1: 4, Y1: 4}, {X0: 4, Y0: 0, X1: 8, Y1: 4}, {X0: 8, Y0: 0, X1: 12, Y1: 4},
+			},
+			// the entrance is passed on to the middle of the first row
+			[]int{2, 4, 2, 2, 3, 2},
+			nil,
+		},
+		{
+			"single cell", `{"rows":1,"columns":1}`, 1,
+			[]area.Rectangle{{X0: 0, Y0: 0, X1: 12, Y1: 8}},
+			[]int{1},
+			nil,
+		},
+		{"no rows", `{"rows":0,"columns":1}`, 0, nil, nil, rule.ErrPreparation},
+		{"no columns", `{"rows":1,"columns":0}`, 0, nil, nil, rule.ErrPreparation},
+		{"wrong number of cells", `{"rows":2,"columns":2}`, 3, nil, nil, rule.ErrPreparation},
+		{"too many rows", `{"rows":5,"columns":1}`, 5, nil, nil, rule.ErrInvalidGraph},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			g, children, err := prepare(t, rule.Grid{}, 12, 8, map[string]int{"cells": c.cells}, c.json)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("expected %v but got %v", c.err, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			if actual := rects(g, children["cells"]); !reflect.DeepEqual(c.rects, actual) {
+				t.Errorf("expected rects %v but got %v", c.rects, actual)
+			}
+			if actual := edges(g, children["cells"]); !reflect.DeepEqual(c.edges, actual) {
+				t.Errorf("expected edges %v but got %v", c.edges, actual)
+			}
+		})
+	}
+}
+
+func TestBSP(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		json  string
+		rooms int
+		rects []area.Rectangle
+		edges []int
+		err   error
+	}{
+		{
+			"one room", `{}`, 1,
+			[]area.Rectangle{{X0: 0, Y0: 0, X1: 13, Y1: 8}},
+			[]int{1},
+			nil,
+		},
+		{
+			"two rooms", `{}`, 2,
+			[]area.Rectangle{{X0: 0, Y0: 0, X1: 6, Y1: 8}, {X0: 6, Y0: 0, X1: 13, Y1: 8}},
+			[]int{1, 2},
+			nil,
+		},
+		{
+			"three rooms", `{}`, 3,
+			[]area.Rectangle{{X0: 0, Y0: 0, X1: 4, Y1: 8}, {X0: 4, Y0: 0, X1: 8, Y1: 8}, {X0: 8, Y0: 0, X1: 13, Y1: 8}},
+			[]int{1, 3, 1},
+			nil,
+		},
+		{
+			"cuts across the longer side", `{"ratio":0.5}`, 3,
+			[]area.Rectangle{
+				{X0: 0, Y0: 0, X1: 6, Y1: 8}, {X0: 6, Y0: 0, X1: 13, Y1: 4}, {X0: 6, Y0: 4, X1: 13, Y1: 8},
+			},
+			[]int{1, 2, 2},
+			nil,
+		},
+		{
+			"ratio", `{"ratio":0.7}`, 2,
+			[]area.Rectangle{{X0: 0, Y0: 0, X1: 8, Y1: 8}, {X0: 8, Y0: 0, X1: 13, Y1: 8}},
+			[]int{2, 1},
+			nil,
+		},
+		{
+			"min-size per room on a side", `{"ratio":0.7}`, 3,
+			[]area.Rectangle{
+				{X0: 0, Y0: 0, X1: 6, Y1: 8}, {X0: 6, Y0: 0, X1: 13, Y1: 4}, {X0: 6, Y0: 4, X1: 13, Y1: 8},
+			},
+			[]int{1, 2, 2},
+			nil,
+		},
+		{
+			"ratio below min-size", `{"ratio":0.1}`, 2,
+			[]area.Rectangle{{X0: 0, Y0: 0, X1: 4, Y1: 8}, {X0: 4, Y0: 0, X1: 13, Y1: 8}},
+			[]int{1, 2},
+			nil,
+		},
+		{"no rooms", `{}`, 0, nil, nil, rule.ErrPreparation},
+		{"min-size not positive", `{"min-size":0}`, 2, nil, nil, rule.ErrPreparation},
+		{"ratio out of range", `{"ratio":1}`, 2, nil, nil, rule.ErrPreparation},
+		{"distribution out of range", `{"ratio":"uniform(0.5,1)"}`, 2, nil, nil, rule.ErrPreparation},
+		{"area below min-size", `{"min-size":8}`, 1, nil, nil, rule.ErrInvalidGraph},
+		{"rooms below min-size", `{"min-size":6}`, 2, nil, nil, rule.ErrInvalidGraph},
+		{"side below min-size per room", `{"min-size":4}`, 3, nil, nil, rule.ErrInvalidGraph},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			g, children, err := prepare(t, rule.BSP{}, 13, 8, map[string]int{"rooms": c.rooms}, c.json)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("expected %v but got %v", c.err, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			if actual := rects(g, children["rooms"]); !reflect.DeepEqual(c.rects, actual) {
+				t.Errorf("expected rects %v but got %v", c.rects, actual)
+			}
+			if actual := edges(g, children["rooms"]); !reflect.DeepEqual(c.edges, actual) {
+				t.Errorf("expected edges %v but got %v", c.edges, actual)
+			}
+		})
+	}
+}
+
+// seeded is a BSP that draws from a source with a fixed seed.
+type seeded struct {
+	rule.BSP
+	seed int64
+}
+
+func (r seeded) PrepareGraph(
+	g *graph.Graph, nidx graph.NodeIndex, children map[string][]graph.NodeIndex, bp *blueprint.Blueprint,
+) error {
+	return r.PrepareGraphWithRand(g, nidx, children, bp, rand.New(rand.NewSource(r.seed)))
+}
+
+func TestBSPDrawsEveryCut(t *testing.T) {
+	// The area is cut into two halves of two rooms each, which are cut again. If the cuts shared their ratio, the
+	// halves would be divided alike.
+	json := `{"ratio":"uniform(0.3,0.7)","min-size":2}`
+	differ := 0
+	for seed := int64(0); seed < 10; seed++ {
+		g, children, err := prepare(t, seeded{seed: seed}, 41, 8, map[string]int{"rooms": 4}, json)
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		widths := make([]float64, 4)
+		for i, rect := range rects(g, children["rooms"]) {
+			widths[i] = float64(rect.X1 - rect.X0 - 1)
+		}
+		if math.Abs(widths[0]/(widths[0]+widths[1])-widths[2]/(widths[2]+widths[3])) > .08 {
+			differ++
+		}
+
+		again, againChildren, _ := prepare(t, seeded{seed: seed}, 41, 8, map[string]int{"rooms": 4}, json)
+		expect, actual := rects(g, children["rooms"]), rects(again, againChildren["rooms"])
+		if !reflect.DeepEqual(expect, actual) {
+			t.Errorf("seed %v: expected the same rects %v but got %v", seed, expect, actual)
+		}
+	}
+	if differ < 5 {
+		t.Errorf("expected the halves to be divided differently for most seeds but only %v were", differ)
+	}
+}
+
+func TestConnectWithoutSharedWall(t *testing.T) {
+	g := graph.New(nil)
+	a, _ := g.Add(graph.NodeIndex{})
+	(*area.AreaNode)(g.Node(a)).SetRect(area.Rectangle{X0: 0, Y0: 0, X1: 4, Y1: 4})
+	b, _ := g.Add(graph.NodeIndex{})
+	(*area.AreaNode)(g.Node(b)).SetRect(area.Rectangle{X0: 4, Y0: 4, X1: 8, Y1: 8})
+
+	if err := rule.Connect(g, []graph.NodeIndex{a}, []graph.NodeIndex{b}); !errors.Is(err, rule.ErrInvalidGraph) {
+		t.Errorf("expected ErrInvalidGraph but got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package rule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nilsbu/arch/pkg/blueprint"
+)
+
+// ErrInvalidParam is returned when the values of a parameter don't match its declaration.
+var ErrInvalidParam = errors.New("invalid parameter")
+
+// A Declarer is a Rule that declares the parameters that it reads values from.
+// In contrast to ChildParams(), these parameters don't become child nodes.
+type Declarer interface {
+	ValueParams() []Param
+}
+
+// A ChildDeclarer is a Rule that declares how many children it needs for its child parameters. Only the names and
+// Min and Max of the Params are used. They limit the number of values of the parameter, each of which is one child.
+type ChildDeclarer interface {
+	ChildCounts() []Param
+}
+
+// A Param declares a parameter that holds values.
+type Param struct {
+	Name string
+	Type ValueType
+	// Required parameters must be defined in the blueprint or one of the ones it can see.
+	Required bool
+	// Min and Max limit the number of values. A Max of 0 means that there is no upper limit.
+	Min, Max int
+	// Enum lists the allowed values. Any value is allowed if it is empty.
+	Enum []string
+	// SameCountAs names another parameter, which may also be a child parameter, that must have as many values.
+	SameCountAs string
+	// Drawn parameters keep their distributions when the graph is prepared. The rule draws from them itself, see
+	// Drawer.
+	Drawn bool
+}
+
+// A ValueType is the type that all values of a parameter must have.
+type ValueType int
+
+const (
+	AnyValue ValueType = iota
+	StringValue
+	IntValue
+	FloatValue
+	BoolValue
+	IntTupleValue
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case StringValue:
+		return "strings"
+	case IntValue:
+		return "integers"
+	case FloatValue:
+		return "numbers"
+	case BoolValue:
+		return "booleans"
+	case IntTupleValue:
+		return "tuples of integers"
+	default:
+		return "any values"
+	}
+}
+
+// check checks if a value has the type. Distributions are accepted when the values they produce have the type.
+func (t ValueType) check(v blueprint.Value) (err error) {
+	if d, ok := v.Distribution(); ok {
+		if t == AnyValue || t == FloatValue || t == IntValue && d.Integer {
+			return nil
+		}
+		return fmt.Errorf("%v cannot be used for %v", d, t)
+	}
+
+	switch t {
+	case StringValue:
+		_, err = v.Text()
+	case IntValue:
+		_, err = v.Int()
+	case FloatValue:
+		_, err = v.Float()
+	case BoolValue:
+		_, err = v.Bool()
+	case IntTupleValue:
+		_, err = v.IntSlice()
+	}
+	return
+}
+
+// Validate checks the values in a blueprint against the parameters that a rule declares. Every problem is returned as
+// a separate error. Rules that are neither Declarers nor ChildDeclarers have no problems.
+func Validate(r Rule, bp *blueprint.Blueprint) []error {
+	var errs []error
+	fail := func(p Param, format string, a ...interface{}) {
+		errs = append(errs, atProperty(bp, p.Name,
+			fmt.Errorf("%w: '%v' %v", ErrInvalidParam, p.Name, fmt.Sprintf(format, a...))))
+	}
+
+	if d, ok := r.(ChildDeclarer); ok {
+		for _, p := range d.ChildCounts() {
+			if n := len(bp.Values(p.Name)); n < p.Min || p.Max > 0 && n > p.Max {
+				fail(p, "must have %v values for children but has %v", count(p.Min, p.Max), n)
+			}
+		}
+	}
+
+	d, ok := r.(Declarer)
+	if !ok {
+		return errs
+	}
+	for _, p := range d.ValueParams() {
+		values := bp.TypedValues(p.Name)
+		if values == nil {
+			if p.Required {
+				fail(p, "is required")
+			}
+			continue
+		}
+
+		if len(values) < p.Min || p.Max > 0 && len(values) > p.Max {
+			fail(p, "must have %v values but has %v", count(p.Min, p.Max), len(values))
+		}
+		if p.SameCountAs != "" {
+			if n := len(bp.Values(p.SameCountAs)); n != len(values) {
+				fail(p, "must have as many values as '%v' (%v) but has %v", p.SameCountAs, n, len(values))
+			}
+		}
+
+		for _, v := range values {
+			if err := p.Type.check(v); err != nil {
+				fail(p, "has invalid value: %v", err)
+			} else if len(p.Enum) > 0 && !contains(p.Enum, v.String()) {
+				fail(p, "has invalid value '%v', allowed are: %v", v, strings.Join(p.Enum, ", "))
+			}
+		}
+	}
+	return errs
+}
+
+func count(min, max int) string {
+	if max == 0 {
+		return fmt.Sprintf("at least %v", min)
+	} else if min == max {
+		return fmt.Sprint(min)
+	} else {
+		return fmt.Sprintf("%v to %v", min, max)
+	}
+}
+
+func contains(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// floatOr returns the value of an optional property or def if it isn't defined.
+func floatOr(bp *blueprint.Blueprint, property string, def float64) (float64, error) {
+	if bp.Values(property) == nil {
+		return def, nil
+	}
+	return bp.Float(property)
+}
+
+// intOr returns the value of an optional property or def if it isn't defined.
+func intOr(bp *blueprint.Blueprint, property string, def int) (int, error) {
+	if bp.Values(property) == nil {
+		return def, nil
+	}
+	return bp.Int(property)
+}
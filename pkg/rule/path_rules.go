@@ -34,6 +34,12 @@ func (r In) ChildParams() []string {
 	return []string{}
 }
 
+func (r In) ValueParams() []Param {
+	return []Param{
+		{Name: "name", Type: StringValue, Required: true, Min: 1, Max: 1},
+	}
+}
+
 func (r In) PrepareGraph(
 	g *graph.Graph,
 	nidx graph.NodeIndex,
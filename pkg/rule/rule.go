@@ -2,6 +2,8 @@ package rule
 
 import (
 	"errors"
+	"fmt"
+	"math/rand"
 
 	"github.com/nilsbu/arch/pkg/blueprint"
 	"github.com/nilsbu/arch/pkg/graph"
@@ -11,6 +13,49 @@ var ErrInvalidGraph = errors.New("invalid graph")
 
 var ErrPreparation = errors.New("error in preparation")
 
+// The following errors tell why a graph is invalid. All of them match ErrInvalidGraph.
+var (
+	ErrTooSmall         = fmt.Errorf("%w: area too small", ErrInvalidGraph)
+	ErrNoRoomForDoors   = fmt.Errorf("%w: no room for doors", ErrInvalidGraph)
+	ErrNoSharedWall     = fmt.Errorf("%w: no shared wall", ErrInvalidGraph)
+	ErrUnassignableDoor = fmt.Errorf("%w: door cannot be assigned", ErrInvalidGraph)
+)
+
+// invalidGraphError marks an error as ErrInvalidGraph while keeping the original error as the wrapped one.
+type invalidGraphError struct {
+	err error
+}
+
+func (e *invalidGraphError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrInvalidGraph, e.err)
+}
+
+func (e *invalidGraphError) Is(target error) bool {
+	return target == ErrInvalidGraph
+}
+
+func (e *invalidGraphError) Unwrap() error {
+	return e.err
+}
+
+// invalid turns an error that is caused by an unsuitable graph into ErrInvalidGraph.
+func invalid(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &invalidGraphError{err}
+}
+
+// atProperty attributes an error to the place where a property is defined in a blueprint. If the property doesn't
+// exist, the blueprint itself is referenced.
+func atProperty(bp *blueprint.Blueprint, property string, err error) error {
+	pos, ok := bp.Position(property)
+	if !ok {
+		pos = bp.Location()
+	}
+	return &blueprint.PositionError{Position: pos, Err: err}
+}
+
 type Rule interface {
 	ChildParams() []string
 	PrepareGraph(
@@ -20,3 +65,17 @@ type Rule interface {
 		bp *blueprint.Blueprint,
 	) error
 }
+
+// A Drawer is a Rule that draws values from distributions itself while it prepares a graph, e.g. a new one for every
+// part that it creates. The parameters that it draws from are declared as Drawn. Builds call PrepareGraphWithRand
+// instead of PrepareGraph and pass their source of randomness, so that the same seed yields the same graph.
+type Drawer interface {
+	Rule
+	PrepareGraphWithRand(
+		g *graph.Graph,
+		nidx graph.NodeIndex,
+		children map[string][]graph.NodeIndex,
+		bp *blueprint.Blueprint,
+		rnd *rand.Rand,
+	) error
+}
@@ -0,0 +1,50 @@
+package rule_test
+
+import (
+	"testing"
+
+	"github.com/nilsbu/arch/pkg/area"
+	"github.com/nilsbu/arch/pkg/blueprint"
+	"github.com/nilsbu/arch/pkg/graph"
+	"github.com/nilsbu/arch/pkg/rule"
+)
+
+// prepare lets a rule prepare an area of the given size with as many children per parameter as counts says. The
+// area is entered from below through a door in the middle.
+func prepare(
+	t *testing.T, r rule.Rule, width, height int, counts map[string]int, json string,
+) (*graph.Graph, map[string][]graph.NodeIndex, error) {
+	t.Helper()
+
+	bp, err := blueprint.Parse([]byte(json))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	g := graph.New(nil)
+	(*area.AreaNode)(g.Node(graph.NodeIndex{})).SetRect(area.Rectangle{X0: 0, Y0: 0, X1: width, Y1: height + 2})
+	nidx, _ := g.Add(graph.NodeIndex{})
+	(*area.AreaNode)(g.Node(nidx)).SetRect(area.Rectangle{X0: 0, Y0: 0, X1: width, Y1: height})
+	outside, _ := g.Add(graph.NodeIndex{})
+	(*area.AreaNode)(g.Node(outside)).SetRect(area.Rectangle{X0: 0, Y0: height, X1: width, Y1: height + 2})
+	if err := area.CreateDoor(g, outside, nidx, .5); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	children := map[string][]graph.NodeIndex{}
+	for _, param := range r.ChildParams() {
+		for i := 0; i < counts[param]; i++ {
+			cnidx, _ := g.Add(nidx)
+			children[param] = append(children[param], cnidx)
+		}
+	}
+	return g, children, r.PrepareGraph(g, nidx, children, bp)
+}
+
+func rects(g *graph.Graph, nidxs []graph.NodeIndex) []area.Rectangle {
+	out := make([]area.Rectangle, len(nidxs))
+	for i, nidx := range nidxs {
+		out[i] = (*area.AreaNode)(g.Node(nidx)).GetRect()
+	}
+	return out
+}
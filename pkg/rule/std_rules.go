@@ -1,9 +1,9 @@
 package rule
 
 import (
-	"encoding/json"
 	"fmt"
-	"strconv"
+	"math"
+	"sort"
 
 	"github.com/nilsbu/arch/pkg/area"
 	"github.com/nilsbu/arch/pkg/blueprint"
@@ -16,6 +16,16 @@ func (r House) ChildParams() []string {
 	return []string{"interior", "exterior"}
 }
 
+func (r House) ChildCounts() []Param {
+	return []Param{{Name: "interior", Min: 1}, {Name: "exterior", Min: 1}}
+}
+
+func (r House) ValueParams() []Param {
+	return append([]Param{
+		{Name: "rect", Type: IntValue, Required: true, Min: 4, Max: 4},
+	}, doorParams...)
+}
+
 func (r House) PrepareGraph(
 	g *graph.Graph,
 	nidx graph.NodeIndex,
@@ -24,9 +34,10 @@ func (r House) PrepareGraph(
 ) error {
 	a := (*area.AreaNode)(g.Node(nidx))
 	a.Properties["render"] = false
-	data := []int{}
-	if err := json.Unmarshal([]byte(bp.Values("rect")[0]), &data); err != nil {
+	if data, err := bp.IntSlice("rect"); err != nil {
 		return err
+	} else if len(data) != 4 {
+		return atProperty(bp, "rect", fmt.Errorf("%w: 'rect' must contain 4 numbers", ErrPreparation))
 	} else {
 		// Add one to have room at the bottom for the exterior
 		data[3]++
@@ -38,9 +49,11 @@ func (r House) PrepareGraph(
 		}
 
 		h := float64(data[3] - data[1])
-		if err := area.Split(g, nidx, nidxs, []float64{(h - 1) / h}, area.Down); err != nil {
+		if doors, err := readDoors(bp); err != nil {
 			return err
-		} else if err := area.CreateDoor(g, children["interior"][0], children["exterior"][0], .5); err != nil {
+		} else if err := area.Split(g, nidx, nidxs, []float64{(h - 1) / h}, area.Down); err != nil {
+			return err
+		} else if err := doors.create(g, children["interior"][0], children["exterior"][0]); err != nil {
 			return err
 		} else {
 			return InheritEdges(g, nidx)
@@ -48,12 +61,28 @@ func (r House) PrepareGraph(
 	}
 }
 
+// Corridor splits an area into a corridor and two strips of rooms on its left and right.
+// The corridor is "width" tiles wide, 3 by default. A width below 1 is a fraction of the area's width. "offset"
+// shifts the corridor from the centre by a fraction of the area's width. Both strips must keep at least "min-depth"
+// tiles, 1 by default, otherwise ErrInvalidGraph is returned.
 type Corridor struct{}
 
 func (r Corridor) ChildParams() []string {
 	return []string{"left", "corridor", "right"}
 }
 
+func (r Corridor) ChildCounts() []Param {
+	return []Param{{Name: "left", Min: 1}, {Name: "corridor", Min: 1}, {Name: "right", Min: 1}}
+}
+
+func (r Corridor) ValueParams() []Param {
+	return append([]Param{
+		{Name: "width", Type: FloatValue, Max: 1},
+		{Name: "offset", Type: FloatValue, Max: 1},
+		{Name: "min-depth", Type: IntValue, Max: 1},
+	}, doorParams...)
+}
+
 func (r Corridor) PrepareGraph(
 	g *graph.Graph,
 	nidx graph.NodeIndex,
@@ -78,10 +107,13 @@ func (r Corridor) PrepareGraph(
 		roomWidth = rect.Y1 - rect.Y0
 	}
 
-	corridorWidth := 3.
-	at := []float64{
-		.5 - corridorWidth/float64(roomWidth)/2,
-		.5 + (corridorWidth+2)/float64(roomWidth)/2,
+	at, err := r.walls(bp, roomWidth)
+	if err != nil {
+		return err
+	}
+	doors, err := readDoors(bp)
+	if err != nil {
+		return err
 	}
 
 	if err := area.Split(g, nidx, nidxs, at, area.Turn(roomOrientation, 90)); err != nil {
@@ -96,7 +128,7 @@ func (r Corridor) PrepareGraph(
 				return err
 			}
 			for _, cnidx := range children[side] {
-				if err := area.CreateDoor(g, children["corridor"][0], cnidx, .5); err != nil {
+				if err := doors.create(g, children["corridor"][0], cnidx); err != nil {
 					return err
 				}
 			}
@@ -105,12 +137,73 @@ func (r Corridor) PrepareGraph(
 	}
 }
 
+// walls returns where the walls of the corridor lie as fractions of the room width.
+func (r Corridor) walls(bp *blueprint.Blueprint, roomWidth int) ([]float64, error) {
+	width, err := floatOr(bp, "width", 3)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := floatOr(bp, "offset", 0)
+	if err != nil {
+		return nil, err
+	}
+	minDepth, err := intOr(bp, "min-depth", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var tiles int
+	if width <= 0 {
+		return nil, atProperty(bp, "width", fmt.Errorf("%w: 'width' must be positive", ErrPreparation))
+	} else if width < 1 {
+		tiles = int(math.Round(width * float64(roomWidth)))
+	} else if width == math.Trunc(width) {
+		tiles = int(width)
+	} else {
+		return nil, atProperty(bp, "width",
+			fmt.Errorf("%w: 'width' must be a fraction below 1 or a whole number of tiles", ErrPreparation))
+	}
+	if offset < -.5 || offset > .5 {
+		return nil, atProperty(bp, "offset", fmt.Errorf("%w: 'offset' must be in [-0.5, 0.5]", ErrPreparation))
+	} else if minDepth < 1 {
+		return nil, atProperty(bp, "min-depth", fmt.Errorf("%w: 'min-depth' must be at least 1", ErrPreparation))
+	}
+
+	// Walls are shared between neighbours, so there are tiles + 1 steps between the corridor's walls.
+	left := int(math.Floor(float64(roomWidth)*(.5+offset) - float64(tiles)/2))
+	right := left + tiles + 1
+	if tiles < 1 {
+		return nil, fmt.Errorf("%w: corridor of width %v is empty in an area of width %v",
+			ErrTooSmall, width, roomWidth)
+	} else if left-1 < minDepth || roomWidth-right-1 < minDepth {
+		return nil, fmt.Errorf("%w: corridor of %v tiles in an area of width %v leaves %v and %v tiles, need %v",
+			ErrTooSmall, tiles, roomWidth, left-1, roomWidth-right-1, minDepth)
+	}
+
+	return []float64{float64(left) / float64(roomWidth), float64(right) / float64(roomWidth)}, nil
+}
+
+// RoomLine puts rooms in a row. The rooms' sizes are proportional to "weights", which has one number per room and
+// defaults to equal weights. "min-size" and "max-size" limit the number of tiles each room gets. They contain either
+// one value for all rooms or one per room. ErrInvalidGraph is returned if the limits cannot be met.
 type RoomLine struct{}
 
 func (r RoomLine) ChildParams() []string {
 	return []string{"rooms"}
 }
 
+func (r RoomLine) ChildCounts() []Param {
+	return []Param{{Name: "rooms", Min: 1}}
+}
+
+func (r RoomLine) ValueParams() []Param {
+	return append([]Param{
+		{Name: "weights", Type: FloatValue, SameCountAs: "rooms"},
+		{Name: "min-size", Type: IntValue},
+		{Name: "max-size", Type: IntValue},
+	}, doorParams...)
+}
+
 func (r RoomLine) PrepareGraph(
 	g *graph.Graph,
 	nidx graph.NodeIndex,
@@ -119,15 +212,19 @@ func (r RoomLine) PrepareGraph(
 ) error {
 	g.Node(nidx).Properties["render"] = false
 	cnidxs := children["rooms"]
-	at := make([]float64, len(cnidxs)-1)
-	for i := range at {
-		at[i] = float64(i+1) / float64(len(cnidxs))
+	shares, err := r.shares(bp, len(cnidxs))
+	if err != nil {
+		return err
 	}
-	if err := area.Split(g, nidx, cnidxs, at, RoomOrientation(g, nidx)); err != nil {
+	doors, err := readDoors(bp)
+	if err != nil {
 		return err
 	}
-	for i := range at {
-		if err := area.CreateDoor(g, cnidxs[i], cnidxs[i+1], .5); err != nil {
+	if err := area.SplitShares(g, nidx, cnidxs, shares, RoomOrientation(g, nidx)); err != nil {
+		return invalid(err)
+	}
+	for i := 0; i < len(cnidxs)-1; i++ {
+		if err := doors.create(g, cnidxs[i], cnidxs[i+1]); err != nil {
 			return err
 		}
 	}
@@ -135,12 +232,73 @@ func (r RoomLine) PrepareGraph(
 	return InheritEdges(g, nidx)
 }
 
+func (r RoomLine) shares(bp *blueprint.Blueprint, n int) ([]area.Share, error) {
+	shares := make([]area.Share, n)
+	for i := range shares {
+		shares[i].Weight = 1
+	}
+
+	if bp.Values("weights") != nil {
+		values := bp.TypedValues("weights")
+		if len(values) != n {
+			return nil, atProperty(bp, "weights",
+				fmt.Errorf("%w: have %v rooms and %v weights", ErrPreparation, n, len(values)))
+		}
+		for i, v := range values {
+			if w, err := v.Float(); err != nil {
+				return nil, atProperty(bp, "weights", err)
+			} else if !(w > 0) {
+				return nil, atProperty(bp, "weights", fmt.Errorf("%w: weight %v isn't positive", ErrPreparation, w))
+			} else {
+				shares[i].Weight = w
+			}
+		}
+	}
+
+	for _, limit := range []struct {
+		property string
+		set      func(s *area.Share, v int)
+	}{
+		{"min-size", func(s *area.Share, v int) { s.Min = v }},
+		{"max-size", func(s *area.Share, v int) { s.Max = v }},
+	} {
+		values := bp.TypedValues(limit.property)
+		if values == nil {
+			continue
+		} else if len(values) != 1 && len(values) != n {
+			return nil, atProperty(bp, limit.property, fmt.Errorf("%w: have %v rooms and %v values for '%v'",
+				ErrPreparation, n, len(values), limit.property))
+		}
+		for i := range shares {
+			if v, err := values[i%len(values)].Int(); err != nil {
+				return nil, atProperty(bp, limit.property, err)
+			} else if v < 0 {
+				return nil, atProperty(bp, limit.property,
+					fmt.Errorf("%w: '%v' must not be negative", ErrPreparation, limit.property))
+			} else {
+				limit.set(&shares[i], v)
+			}
+		}
+	}
+	for _, share := range shares {
+		if share.Max > 0 && share.Max < share.Min {
+			return nil, atProperty(bp, "max-size",
+				fmt.Errorf("%w: 'max-size' %v is below 'min-size' %v", ErrPreparation, share.Max, share.Min))
+		}
+	}
+	return shares, nil
+}
+
 type Frame struct{}
 
 func (r Frame) ChildParams() []string {
 	return []string{"content"}
 }
 
+func (r Frame) ChildCounts() []Param {
+	return []Param{{Name: "content", Min: 1, Max: 1}}
+}
+
 func (r Frame) PrepareGraph(
 	g *graph.Graph,
 	nidx graph.NodeIndex,
@@ -208,6 +366,19 @@ func (r Furniture) ChildParams() []string {
 	return []string{"elements"}
 }
 
+func (r Furniture) ValueParams() []Param {
+	names := make([]string, 0, len(anchors))
+	for name := range anchors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return []Param{
+		{Name: "sizes", Type: IntTupleValue, Required: true, SameCountAs: "elements"},
+		{Name: "anchors", Type: StringValue, Required: true, Enum: names, SameCountAs: "elements"},
+	}
+}
+
 func (r Furniture) PrepareGraph(
 	g *graph.Graph,
 	nidx graph.NodeIndex,
@@ -217,30 +388,32 @@ func (r Furniture) PrepareGraph(
 	SetWall(g, nidx, false)
 
 	elements := children["elements"]
-	sizes := bp.Values("sizes")
+	sizes := bp.TypedValues("sizes")
 	anchors := bp.Values("anchors")
 	if len(elements) != len(sizes) {
-		return fmt.Errorf("%w: have %v elements and %v sizes",
-			ErrPreparation, len(elements), len(sizes))
+		return atProperty(bp, "sizes", fmt.Errorf("%w: have %v elements and %v sizes",
+			ErrPreparation, len(elements), len(sizes)))
 	} else if len(elements) != len(anchors) {
-		return fmt.Errorf("%w: have %v elements and %v anchors",
-			ErrPreparation, len(elements), len(anchors))
+		return atProperty(bp, "anchors", fmt.Errorf("%w: have %v elements and %v anchors",
+			ErrPreparation, len(elements), len(anchors)))
 	} else {
 		a := (*area.AreaNode)(g.Node(nidx))
 		rect := a.GetRect()
 		roomOrientation := a.Properties["orientation"].(area.Direction)
 		for i := range sizes {
-			size := []int{}
-			if err := json.Unmarshal([]byte(sizes[i]), &size); err != nil {
-				return err
+			if size, err := sizes[i].IntSlice(); err != nil {
+				return atProperty(bp, "sizes", err)
+			} else if len(size) != 2 {
+				return atProperty(bp, "sizes",
+					fmt.Errorf("%w: size '%v' must contain 2 numbers", ErrPreparation, sizes[i]))
 			} else if anchor, err := getAnchor(anchors[i]); err != nil {
-				return err
+				return atProperty(bp, "anchors", err)
 			} else {
 				e := (*area.AreaNode)(g.Node(elements[i]))
 
 				preRect := intoCorner(size, rect, anchor)
 				if postRect, err := area.RotateWithin(preRect, rect, area.Down, roomOrientation, anchor); err != nil {
-					return fmt.Errorf("%w: %v", ErrInvalidGraph, err)
+					return invalid(err)
 				} else {
 					e.SetRect(postRect)
 				}
@@ -250,19 +423,18 @@ func (r Furniture) PrepareGraph(
 	}
 }
 
+var anchors = map[string]area.Anchor{
+	"near-left":  area.NearLeft,
+	"far-left":   area.FarLeft,
+	"near-right": area.NearRight,
+	"far-right":  area.FarRight,
+	"center":     area.Center,
+}
+
 func getAnchor(str string) (area.Anchor, error) {
-	switch str {
-	case "near-left":
-		return area.NearLeft, nil
-	case "far-left":
-		return area.FarLeft, nil
-	case "near-right":
-		return area.NearRight, nil
-	case "far-right":
-		return area.FarRight, nil
-	case "center":
-		return area.Center, nil
-	default:
+	if anchor, ok := anchors[str]; ok {
+		return anchor, nil
+	} else {
 		return 0, fmt.Errorf("%w: '%v' is no valid anchor", ErrPreparation, str)
 	}
 }
@@ -322,6 +494,12 @@ func (r Occupy) ChildParams() []string {
 	return []string{}
 }
 
+func (r Occupy) ValueParams() []Param {
+	return []Param{
+		{Name: "texture", Type: IntValue, Required: true, Min: 1, Max: 1},
+	}
+}
+
 func (r Occupy) PrepareGraph(
 	g *graph.Graph,
 	nidx graph.NodeIndex,
@@ -330,9 +508,7 @@ func (r Occupy) PrepareGraph(
 ) error {
 	SetWall(g, nidx, false)
 
-	if texture := bp.Values("texture"); len(texture) != 1 {
-		return ErrPreparation
-	} else if tex, err := strconv.Atoi(texture[0]); err != nil {
+	if tex, err := bp.Int("texture"); err != nil {
 		return err
 	} else {
 		g.Node(nidx).Properties["object"] = tex
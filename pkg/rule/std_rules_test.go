@@ -0,0 +1,100 @@
+package rule_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nilsbu/arch/pkg/area"
+	"github.com/nilsbu/arch/pkg/rule"
+)
+
+func TestCorridor(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		json     string
+		corridor area.Rectangle
+		err      error
+	}{
+		{"default", `{}`, area.Rectangle{X0: 4, Y0: 0, X1: 8, Y1: 8}, nil},
+		{"width in tiles", `{"width":5}`, area.Rectangle{X0: 3, Y0: 0, X1: 9, Y1: 8}, nil},
+		{"width as fraction", `{"width":0.5}`, area.Rectangle{X0: 3, Y0: 0, X1: 10, Y1: 8}, nil},
+		{"offset", `{"offset":0.0625}`, area.Rectangle{X0: 5, Y0: 0, X1: 9, Y1: 8}, nil},
+		{"negative offset", `{"offset":-0.125}`, area.Rectangle{X0: 3, Y0: 0, X1: 7, Y1: 8}, nil},
+		{"min-depth", `{"min-depth":3}`, area.Rectangle{X0: 4, Y0: 0, X1: 8, Y1: 8}, nil},
+		{"zero width", `{"width":0}`, area.Rectangle{}, rule.ErrPreparation},
+		{"fractional tiles", `{"width":2.5}`, area.Rectangle{}, rule.ErrPreparation},
+		{"offset too large", `{"offset":0.6}`, area.Rectangle{}, rule.ErrPreparation},
+		{"offset too small", `{"offset":-0.6}`, area.Rectangle{}, rule.ErrPreparation},
+		{"min-depth not positive", `{"min-depth":0}`, area.Rectangle{}, rule.ErrPreparation},
+		{"empty corridor", `{"width":0.01}`, area.Rectangle{}, rule.ErrInvalidGraph},
+		{"strips too shallow", `{"min-depth":4}`, area.Rectangle{}, rule.ErrInvalidGraph},
+		{"offset leaves no strip", `{"offset":0.25}`, area.Rectangle{}, rule.ErrInvalidGraph},
+		{"offset at bound", `{"offset":-0.5}`, area.Rectangle{}, rule.ErrInvalidGraph},
+		{"area too small", `{"width":10}`, area.Rectangle{}, rule.ErrInvalidGraph},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			g, children, err := prepare(t, rule.Corridor{}, 12, 8,
+				map[string]int{"left": 1, "corridor": 1, "right": 1}, c.json)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("expected %v but got %v", c.err, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			expect := []area.Rectangle{
+				{X0: 0, Y0: 0, X1: c.corridor.X0, Y1: 8},
+				c.corridor,
+				{X0: c.corridor.X1, Y0: 0, X1: 12, Y1: 8},
+			}
+			actual := rects(g, append(append(children["left"], children["corridor"]...), children["right"]...))
+			if !reflect.DeepEqual(expect, actual) {
+				t.Errorf("expected %v but got %v", expect, actual)
+			}
+		})
+	}
+}
+
+func TestRoomLine(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		json  string
+		sizes []int
+		err   error
+	}{
+		{"equal", `{}`, []int{5, 5}, nil},
+		{"weights", `{"weights":[1,4]}`, []int{2, 8}, nil},
+		{"min-size per room", `{"min-size":[7,1]}`, []int{7, 3}, nil},
+		{"max-size per room", `{"max-size":[2,20]}`, []int{2, 8}, nil},
+		{"min-size too big", `{"min-size":7}`, nil, rule.ErrInvalidGraph},
+		{"max-size too small", `{"max-size":3}`, nil, rule.ErrInvalidGraph},
+		{"too many weights", `{"weights":[1,2,3]}`, nil, rule.ErrPreparation},
+		{"zero weight", `{"weights":[1,0]}`, nil, rule.ErrPreparation},
+		{"too many limits", `{"min-size":[1,2,3]}`, nil, rule.ErrPreparation},
+		{"negative limit", `{"min-size":-1}`, nil, rule.ErrPreparation},
+		{"max-size below min-size", `{"min-size":4,"max-size":3}`, nil, rule.ErrPreparation},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			g, children, err := prepare(t, rule.RoomLine{}, 8, 12, map[string]int{"rooms": 2}, c.json)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("expected %v but got %v", c.err, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			sizes := make([]int, len(children["rooms"]))
+			for i, rect := range rects(g, children["rooms"]) {
+				sizes[i] = rect.Y1 - rect.Y0 - 1
+			}
+			if !reflect.DeepEqual(c.sizes, sizes) {
+				t.Errorf("expected sizes %v but got %v", c.sizes, sizes)
+			}
+		})
+	}
+}
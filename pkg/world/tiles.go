@@ -11,8 +11,16 @@ const (
 	Wall
 	Door
 	Occupied
+	Archway
+	Gate
+	LockedDoor
 )
 
+// IsPassage returns whether the tile type is part of a wall through which areas are connected.
+func (t TileType) IsPassage() bool {
+	return t == Door || t == Archway || t == Gate || t == LockedDoor
+}
+
 // A Tile is the content of a slot in Tiles.
 // It contains information about the TileType and about the appearance.
 type Tile struct {